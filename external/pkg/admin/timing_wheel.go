@@ -0,0 +1,69 @@
+package admin
+
+import (
+	"github.com/kataras/iris/v12"
+	"github.com/liupei0210/webtools/external/pkg/response"
+	"github.com/liupei0210/webtools/external/pkg/utils"
+)
+
+// 未指定 n 时 /tasks/next 返回的任务数
+const defaultPeekSize = 10
+
+// RegisterTimingWheel 在 party 下注册时间轮的运维接口，响应统一使用 response.Result：
+//
+//	GET    /tasks             所有任务，按预期触发时间排序，可通过 state=pending|running 过滤
+//	GET    /tasks/next?n=10   最早触发的 n 个未触发任务
+//	GET    /slots             每个槽位的任务数
+//	GET    /failures          最近的失败记录
+//	GET    /metrics           指标快照，未启用指标时 data 为空
+//	DELETE /tasks/{id}        取消任务
+//	POST   /tasks/{id}/fire   立即触发任务
+func RegisterTimingWheel(party iris.Party, tw *utils.TimingWheel) {
+	party.Get("/tasks", func(ctx iris.Context) {
+		state := utils.TaskState(ctx.URLParam("state"))
+		tasks := make([]utils.TaskInfo, 0)
+		for _, t := range tw.Tasks() {
+			if state == "" || t.State == state {
+				tasks = append(tasks, t)
+			}
+		}
+		_ = ctx.JSON(response.Succeed(tasks))
+	})
+	party.Get("/tasks/next", func(ctx iris.Context) {
+		n := ctx.URLParamIntDefault("n", defaultPeekSize)
+		if n <= 0 {
+			_ = ctx.JSON(response.ValidateError("n 必须大于0"))
+			return
+		}
+		_ = ctx.JSON(response.Succeed(tw.PeekDue(n)))
+	})
+	party.Get("/slots", func(ctx iris.Context) {
+		_ = ctx.JSON(response.Succeed(tw.SlotCounts()))
+	})
+	party.Get("/failures", func(ctx iris.Context) {
+		_ = ctx.JSON(response.Succeed(tw.RecentFailures()))
+	})
+	party.Get("/metrics", func(ctx iris.Context) {
+		_ = ctx.JSON(response.Succeed(tw.MetricsSnapshot()))
+	})
+	party.Delete("/tasks/{id}", func(ctx iris.Context) {
+		id := ctx.Params().Get("id")
+		if err := tw.CancelTask(id); err != nil {
+			utils.GetLogger().Warnf("取消任务 %s 失败: %v", id, err)
+			_ = ctx.JSON(response.Fail(err.Error()))
+			return
+		}
+		utils.GetLogger().Infof("通过运维接口取消任务: %s", id)
+		_ = ctx.JSON(response.Succeed(nil))
+	})
+	party.Post("/tasks/{id}/fire", func(ctx iris.Context) {
+		id := ctx.Params().Get("id")
+		if err := tw.FireNow(id); err != nil {
+			utils.GetLogger().Warnf("立即触发任务 %s 失败: %v", id, err)
+			_ = ctx.JSON(response.Fail(err.Error()))
+			return
+		}
+		utils.GetLogger().Infof("通过运维接口立即触发任务: %s", id)
+		_ = ctx.JSON(response.Succeed(nil))
+	})
+}
@@ -0,0 +1,77 @@
+package admin
+
+import (
+	"encoding/json"
+	"github.com/kataras/iris/v12"
+	"github.com/liupei0210/webtools/external/pkg/response"
+	"github.com/liupei0210/webtools/external/pkg/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRegisterTimingWheel(t *testing.T) {
+	tw := utils.NewTimingWheel(1, 60, utils.WithClock(utils.NewFakeClock(time.Now())))
+	if err := tw.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer tw.Stop()
+
+	fired := make(chan any, 1)
+	for _, id := range []string{"a", "b"} {
+		err := tw.AddErrTask(id, func(data any, tc utils.TaskContext) error {
+			fired <- data
+			return nil
+		}, time.Minute, utils.WithTaskID(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	app := iris.New()
+	RegisterTimingWheel(app.Party("/admin/timing-wheel"), tw)
+	if err := app.Build(); err != nil {
+		t.Fatal(err)
+	}
+	call := func(method, path string, data any) response.Result {
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, httptest.NewRequest(method, "/admin/timing-wheel"+path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s %s 返回状态码 %d", method, path, rec.Code)
+		}
+		res := response.Result{Data: data}
+		if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	ok := response.Succeed(nil).Status
+
+	var tasks []utils.TaskInfo
+	if res := call(http.MethodGet, "/tasks", &tasks); res.Status != ok || len(tasks) != 2 {
+		t.Fatalf("任务列表不正确: %+v", res)
+	}
+	if res := call(http.MethodDelete, "/tasks/a", nil); res.Status != ok {
+		t.Errorf("取消任务失败: %+v", res)
+	}
+	if res := call(http.MethodDelete, "/tasks/a", nil); res.Status == ok {
+		t.Error("重复取消任务应失败")
+	}
+	if res := call(http.MethodPost, "/tasks/b/fire", nil); res.Status != ok {
+		t.Fatalf("立即触发任务失败: %+v", res)
+	}
+	if data := <-fired; data != "b" {
+		t.Errorf("触发的任务数据为 %v，期望 b", data)
+	}
+
+	var slots []int
+	call(http.MethodGet, "/slots", &slots)
+	if len(slots) != 60 {
+		t.Errorf("槽位数为 %d，期望 60", len(slots))
+	}
+	var next []utils.TaskInfo
+	if res := call(http.MethodGet, "/tasks/next?n=5", &next); res.Status != ok || len(next) != 0 {
+		t.Errorf("不应再有未触发任务: %+v", next)
+	}
+}
@@ -0,0 +1,193 @@
+package httpmock
+
+import (
+	"context"
+	"github.com/liupei0210/webtools/external/pkg/utils"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type user struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestServer(t *testing.T) {
+	srv := NewServer(t)
+	get := srv.On(http.MethodGet, "/users/*", Query("verbose", "1")).Reply(http.StatusOK, user{ID: 1, Name: "a"}).Times(1)
+	srv.On(http.MethodPost, "/users", JSONBody(map[string]any{"name": "b"})).Reply(http.StatusCreated, user{ID: 2, Name: "b"})
+	srv.On(http.MethodPost, "/users").Reply(http.StatusBadRequest, "invalid").ReplyHeader("Content-Type", "text/plain")
+
+	res, err := utils.DoRequest[user](http.MethodGet, srv.URL, "/users/1", nil, url.Values{"verbose": {"1"}}, nil)
+	if err != nil || res.ID != 1 {
+		t.Fatalf("应返回桩响应: %v %v", res, err)
+	}
+	if get.Calls() != 1 {
+		t.Errorf("调用次数为 %d，期望 1", get.Calls())
+	}
+
+	res, err = utils.DoRequest[user](http.MethodPost, srv.URL, "/users", nil, nil, []byte(`{ "name" : "b" }`))
+	if err != nil || res.ID != 2 {
+		t.Errorf("JSON 请求体应按语义匹配: %v %v", res, err)
+	}
+	_, err = utils.DoRequest[user](http.MethodPost, srv.URL, "/users", nil, nil, []byte(`{"name":"c"}`))
+	if httpErr, ok := err.(*utils.HTTPError); !ok || httpErr.StatusCode != http.StatusBadRequest || string(httpErr.Body) != "invalid" {
+		t.Errorf("应匹配后添加的路由: %v", err)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	srv := NewServer(t)
+	srv.On(http.MethodGet, "/users/1").Reply(http.StatusOK, user{ID: 1, Name: "a"}).Times(1)
+	srv.On(http.MethodPost, "/users", JSONBody(user{Name: "b"})).Reply(http.StatusCreated, user{ID: 2, Name: "b"}).Times(1)
+	srv.On(http.MethodGet, "/bytes").Reply(http.StatusOK, []byte{0xff, 0x00, 0xfe}).Times(1)
+	golden := filepath.Join(t.TempDir(), "testdata", "users.json")
+
+	// 录制
+	rec := NewRecorder(t, golden, WithMode(ModeRecord))
+	send(t, utils.NewHttpClientWrapper(srv.URL, utils.WithTransport(rec)))
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 回放时不再请求桩服务器，域名不同也能匹配
+	replay := NewRecorder(t, golden, WithMode(ModeReplay))
+	send(t, utils.NewHttpClientWrapper("http://upstream.invalid", utils.WithTransport(replay)))
+	if unused := replay.Unused(); len(unused) != 0 {
+		t.Errorf("所有录制记录都应被使用，剩余 %d 条", len(unused))
+	}
+
+	w := utils.NewHttpClientWrapper("http://upstream.invalid", utils.WithTransport(replay), utils.WithRetry(0, 0))
+	if _, err := w.Do(context.Background(), http.MethodGet, "/users/1"); err == nil {
+		t.Error("录制记录用完后应返回错误")
+	}
+}
+
+func TestRecorderRedact(t *testing.T) {
+	srv := NewServer(t)
+	srv.On(http.MethodGet, "/users/1").Reply(http.StatusOK, user{ID: 1, Name: "a"}).ReplyHeader("Set-Cookie", "session=secret-session")
+	golden := filepath.Join(t.TempDir(), "users.json")
+
+	get := func(w *utils.HttpClientWrapper, token, sig string) {
+		t.Helper()
+		resp, err := w.Do(context.Background(), http.MethodGet, "/users/1",
+			utils.WithHeader("Authorization", "Bearer "+token),
+			utils.WithQuery(url.Values{"access_token": {token}, "sig": {sig}, "page": {"1"}}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u, err := utils.HandleResponse[user](resp); err != nil || u.ID != 1 {
+			t.Fatalf("响应错误: %v %v", u, err)
+		}
+	}
+
+	rec := NewRecorder(t, golden, WithMode(ModeRecord), RedactQuery("SIG"))
+	u, _ := url.Parse(srv.URL)
+	u.User = url.UserPassword("admin", "secret-password")
+	get(utils.NewHttpClientWrapper(u.String(), utils.WithTransport(rec)), "secret-token", "secret-sig")
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"secret-session", "secret-token", "secret-sig", "secret-password", "admin"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("golden 文件不应包含敏感信息 %s:\n%s", secret, data)
+		}
+	}
+	if !strings.Contains(string(data), "page=1") {
+		t.Errorf("未配置脱敏的查询参数应保留:\n%s", data)
+	}
+
+	// 回放时敏感参数的值不同也能匹配
+	replay := NewRecorder(t, golden, WithMode(ModeReplay), RedactQuery("sig"))
+	get(utils.NewHttpClientWrapper("http://upstream.invalid", utils.WithTransport(replay)), "other-token", "other-sig")
+}
+
+func TestRecorderRedactBody(t *testing.T) {
+	srv := NewServer(t)
+	srv.On(http.MethodPost, "/token").
+		Reply(http.StatusOK, map[string]any{"access_token": "secret-access-token", "token_type": "bearer", "expires_in": 3600})
+	srv.On(http.MethodPost, "/login").Reply(http.StatusOK, user{ID: 1, Name: "a"})
+	golden := filepath.Join(t.TempDir(), "token.json")
+
+	run := func(transport http.RoundTripper, secret string) {
+		t.Helper()
+		cc := utils.NewClientCredentials(utils.ClientCredentialsConfig{
+			TokenURL:     srv.URL + "/token",
+			ClientID:     "app",
+			ClientSecret: secret,
+			AuthInParams: true,
+			Client:       utils.NewHttpClientWrapper("", utils.WithTransport(transport)),
+		})
+		if token, err := cc.Token(context.Background()); err != nil || token.AccessToken == "" {
+			t.Fatalf("获取令牌失败: %v %v", token, err)
+		}
+		w := utils.NewHttpClientWrapper(srv.URL, utils.WithTransport(transport))
+		resp, err := w.Do(context.Background(), http.MethodPost, "/login",
+			utils.WithJSONBody(map[string]any{"user": map[string]string{"name": "a", "password": secret}}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u, err := utils.HandleResponse[user](resp); err != nil || u.ID != 1 {
+			t.Fatalf("响应错误: %v %v", u, err)
+		}
+	}
+
+	rec := NewRecorder(t, golden, WithMode(ModeRecord))
+	run(rec, "secret-client")
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"secret-client", "secret-access-token"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("golden 文件不应包含敏感信息 %s:\n%s", secret, data)
+		}
+	}
+	if !strings.Contains(string(data), "client_id=app") {
+		t.Errorf("未配置脱敏的字段应保留:\n%s", data)
+	}
+
+	// 回放时密钥不同也能匹配
+	replay := NewRecorder(t, golden, WithMode(ModeReplay))
+	run(replay, "other-client")
+	if unused := replay.Unused(); len(unused) != 0 {
+		t.Errorf("所有录制记录都应被使用，剩余 %d 条", len(unused))
+	}
+}
+
+func send(t *testing.T, w *utils.HttpClientWrapper) {
+	t.Helper()
+	resp, err := w.Do(context.Background(), http.MethodGet, "/users/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u, err := utils.HandleResponse[user](resp); err != nil || u.Name != "a" {
+		t.Errorf("GET 响应错误: %v %v", u, err)
+	}
+	resp, err = w.Do(context.Background(), http.MethodPost, "/users", utils.WithJSONBody(user{Name: "b"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u, err := utils.HandleResponse[user](resp); err != nil || u.ID != 2 {
+		t.Errorf("POST 响应错误: %v %v", u, err)
+	}
+	resp, err = w.Do(context.Background(), http.MethodGet, "/bytes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := utils.HandleResponse[[]byte](resp); err != nil || string(b) != "\xff\x00\xfe" {
+		t.Errorf("二进制响应错误: %v %v", b, err)
+	}
+}
@@ -0,0 +1,445 @@
+package httpmock
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+)
+
+// RecordEnv 设置该环境变量（非空）时 NewRecorder 默认使用录制模式
+const RecordEnv = "HTTPMOCK_RECORD"
+
+// Mode 录制或回放
+type Mode int
+
+const (
+	ModeReplay Mode = iota // 从 golden 文件回放，不发送真实请求
+	ModeRecord             // 发送真实请求，测试结束时写入 golden 文件
+)
+
+// Redacted 录制时替换敏感信息的占位值
+const Redacted = "REDACTED"
+
+var (
+	defaultRedactHeaders = []string{"Authorization", "Cookie", "Set-Cookie"}
+	defaultRedactQuery   = []string{"access_token", "api_key", "token"}
+	defaultRedactFields  = []string{"access_token", "client_secret", "password", "refresh_token"}
+)
+
+// MatchField 回放时用于匹配录制记录的请求字段
+type MatchField int
+
+const (
+	MatchMethod MatchField = iota
+	MatchPath
+	MatchQuery
+	MatchBody // 请求体均为 JSON 时按语义比较
+)
+
+// Interaction 录制的一次请求和响应
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest 录制的请求，只记录 Content-Type 请求头，URL 中的用户信息、敏感查询参数和请求体字段已脱敏
+type RecordedRequest struct {
+	Method       string `json:"method"`
+	URL          string `json:"url"`
+	ContentType  string `json:"contentType,omitempty"`
+	Body         string `json:"body,omitempty"`
+	BodyEncoding string `json:"bodyEncoding,omitempty"` // 非 UTF-8 内容为 base64
+}
+
+// RecordedResponse 录制的响应，敏感响应头和响应体字段已脱敏
+type RecordedResponse struct {
+	StatusCode   int         `json:"statusCode"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"bodyEncoding,omitempty"`
+}
+
+// RecorderOption Recorder 选项
+type RecorderOption func(*Recorder)
+
+// WithMode 指定录制或回放，默认根据 HTTPMOCK_RECORD 环境变量决定
+func WithMode(mode Mode) RecorderOption {
+	return func(r *Recorder) {
+		r.mode = mode
+	}
+}
+
+// WithRealTransport 录制模式下发送真实请求的 RoundTripper，默认 http.DefaultTransport
+func WithRealTransport(transport http.RoundTripper) RecorderOption {
+	return func(r *Recorder) {
+		r.real = transport
+	}
+}
+
+// MatchOn 回放时匹配的请求字段，默认匹配方法、路径、查询参数和请求体
+func MatchOn(fields ...MatchField) RecorderOption {
+	return func(r *Recorder) {
+		r.matchOn = fields
+	}
+}
+
+// RedactHeaders 录制时额外脱敏的响应头，默认脱敏 Authorization、Cookie 和 Set-Cookie
+func RedactHeaders(names ...string) RecorderOption {
+	return func(r *Recorder) {
+		for _, name := range names {
+			r.redactHeaders[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+}
+
+// RedactQuery 录制时额外脱敏的查询参数，不区分大小写，默认脱敏 access_token、api_key 和 token。
+// 回放时请求中的这些参数同样按脱敏后的值匹配。
+func RedactQuery(keys ...string) RecorderOption {
+	return func(r *Recorder) {
+		for _, key := range keys {
+			r.redactQuery[strings.ToLower(key)] = true
+		}
+	}
+}
+
+// RedactBodyFields 录制时额外脱敏的请求体和响应体字段，不区分大小写，
+// 默认脱敏 access_token、client_secret、password 和 refresh_token。
+// 只处理表单和 JSON 内容，JSON 中任意层级的同名字段均会脱敏；回放时请求体同样按脱敏后的内容匹配。
+func RedactBodyFields(keys ...string) RecorderOption {
+	return func(r *Recorder) {
+		for _, key := range keys {
+			r.redactFields[strings.ToLower(key)] = true
+		}
+	}
+}
+
+// Recorder 录制和回放请求的 http.RoundTripper，并发安全。
+// 回放时按录制顺序查找第一条未使用且匹配的记录，同一请求录制多次时依次返回。
+type Recorder struct {
+	t       testing.TB
+	golden  string
+	mode    Mode
+	real    http.RoundTripper
+	matchOn []MatchField
+
+	redactHeaders map[string]bool // 规范化的响应头名称
+	redactQuery   map[string]bool // 小写的查询参数名
+	redactFields  map[string]bool // 小写的请求体和响应体字段名
+
+	mutex        sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// NewRecorder 创建 Recorder，回放模式下加载 golden 文件，录制模式下测试结束时写入 golden 文件
+func NewRecorder(t testing.TB, golden string, opts ...RecorderOption) *Recorder {
+	t.Helper()
+	r := &Recorder{
+		t:       t,
+		golden:  golden,
+		real:    http.DefaultTransport,
+		matchOn: []MatchField{MatchMethod, MatchPath, MatchQuery, MatchBody},
+
+		redactHeaders: make(map[string]bool),
+		redactQuery:   make(map[string]bool),
+		redactFields:  make(map[string]bool),
+	}
+	if os.Getenv(RecordEnv) != "" {
+		r.mode = ModeRecord
+	}
+	RedactHeaders(defaultRedactHeaders...)(r)
+	RedactQuery(defaultRedactQuery...)(r)
+	RedactBodyFields(defaultRedactFields...)(r)
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if r.mode == ModeRecord {
+		t.Cleanup(func() {
+			if err := r.Save(); err != nil {
+				t.Error(err)
+			}
+		})
+		return r
+	}
+	data, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("读取 golden 文件失败（设置 %s=1 重新录制）: %v", RecordEnv, err)
+	}
+	if err = json.Unmarshal(data, &r.interactions); err != nil {
+		t.Fatalf("解析 golden 文件 %s 失败: %v", golden, err)
+	}
+	r.used = make([]bool, len(r.interactions))
+	return r
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, fmt.Errorf("读取请求体失败: %v", err)
+		}
+		_ = req.Body.Close()
+	}
+	if r.mode == ModeRecord {
+		return r.record(req, body)
+	}
+	return r.replay(req, body)
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	resp, err := r.real.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("读取响应体失败: %v", err)
+	}
+
+	interaction := Interaction{
+		Request: RecordedRequest{
+			Method:      req.Method,
+			URL:         r.redactURL(req.URL).String(),
+			ContentType: req.Header.Get("Content-Type"),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     r.redactHeader(resp.Header),
+		},
+	}
+	interaction.Request.Body, interaction.Request.BodyEncoding = encodeBody(r.redactBody(req.Header.Get("Content-Type"), body))
+	interaction.Response.Body, interaction.Response.BodyEncoding = encodeBody(r.redactBody(resp.Header.Get("Content-Type"), respBody))
+	r.mutex.Lock()
+	r.interactions = append(r.interactions, interaction)
+	r.mutex.Unlock()
+
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	return resp, nil
+}
+
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i, interaction := range r.interactions {
+		if r.used[i] || !r.matches(interaction.Request, req, body) {
+			continue
+		}
+		r.used[i] = true
+		respBody, err := decodeBody(interaction.Response.Body, interaction.Response.BodyEncoding)
+		if err != nil {
+			return nil, err
+		}
+		header := interaction.Response.Header.Clone()
+		if header == nil {
+			header = make(http.Header)
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+			StatusCode:    interaction.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(respBody)),
+			ContentLength: int64(len(respBody)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("httpmock: %s 中没有与 %s %s 匹配的录制记录", r.golden, req.Method, r.redactURL(req.URL))
+}
+
+// redactURL 返回去掉用户信息并脱敏查询参数的 URL 副本
+func (r *Recorder) redactURL(u *url.URL) *url.URL {
+	out := *u
+	out.User = nil
+	query := out.Query()
+	changed := false
+	for key, values := range query {
+		if !r.redactQuery[strings.ToLower(key)] {
+			continue
+		}
+		for i := range values {
+			values[i] = Redacted
+		}
+		changed = true
+	}
+	if changed {
+		out.RawQuery = query.Encode()
+	}
+	return &out
+}
+
+// redactBody 脱敏表单和 JSON 内容中的敏感字段，没有需要脱敏的字段或无法解析时原样返回
+func (r *Recorder) redactBody(contentType string, body []byte) []byte {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return body
+		}
+		changed := false
+		for key, values := range form {
+			if !r.redactFields[strings.ToLower(key)] {
+				continue
+			}
+			for i := range values {
+				values[i] = Redacted
+			}
+			changed = true
+		}
+		if changed {
+			return []byte(form.Encode())
+		}
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		var v any
+		if decoder.Decode(&v) != nil || !r.redactJSON(v) {
+			return body
+		}
+		if data, err := json.Marshal(v); err == nil {
+			return data
+		}
+	}
+	return body
+}
+
+// redactJSON 就地脱敏 JSON 值中的敏感字段，返回是否有字段被脱敏
+func (r *Recorder) redactJSON(v any) bool {
+	changed := false
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if r.redactFields[strings.ToLower(key)] {
+				v[key] = Redacted
+				changed = true
+				continue
+			}
+			if r.redactJSON(value) {
+				changed = true
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if r.redactJSON(item) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// redactHeader 返回脱敏敏感响应头的副本
+func (r *Recorder) redactHeader(header http.Header) http.Header {
+	out := header.Clone()
+	for key, values := range out {
+		if !r.redactHeaders[key] {
+			continue
+		}
+		for i := range values {
+			values[i] = Redacted
+		}
+	}
+	return out
+}
+
+func (r *Recorder) matches(recorded RecordedRequest, req *http.Request, body []byte) bool {
+	u, err := url.Parse(recorded.URL)
+	if err != nil {
+		return false
+	}
+	for _, field := range r.matchOn {
+		switch field {
+		case MatchMethod:
+			if recorded.Method != req.Method {
+				return false
+			}
+		case MatchPath:
+			if u.Path != req.URL.Path {
+				return false
+			}
+		case MatchQuery:
+			if u.Query().Encode() != r.redactURL(req.URL).Query().Encode() {
+				return false
+			}
+		case MatchBody:
+			recordedBody, err := decodeBody(recorded.Body, recorded.BodyEncoding)
+			if err != nil {
+				return false
+			}
+			redactedBody := r.redactBody(req.Header.Get("Content-Type"), body)
+			if !bytes.Equal(recordedBody, redactedBody) && !jsonEqual(recordedBody, redactedBody) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Unused 返回回放模式下未被使用的录制记录，可用于检查请求是否少于预期
+func (r *Recorder) Unused() []Interaction {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var unused []Interaction
+	for i, interaction := range r.interactions {
+		if i < len(r.used) && !r.used[i] {
+			unused = append(unused, interaction)
+		}
+	}
+	return unused
+}
+
+// Save 将录制的记录写入 golden 文件，录制模式下测试结束时自动调用
+func (r *Recorder) Save() error {
+	r.mutex.Lock()
+	data, err := json.MarshalIndent(r.interactions, "", "  ")
+	r.mutex.Unlock()
+	if err != nil {
+		return fmt.Errorf("序列化录制记录失败: %v", err)
+	}
+	if err = os.MkdirAll(filepath.Dir(r.golden), 0755); err != nil {
+		return fmt.Errorf("创建目录失败: %v", err)
+	}
+	if err = os.WriteFile(r.golden, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("写入 golden 文件失败: %v", err)
+	}
+	return nil
+}
+
+func encodeBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+func decodeBody(body, encoding string) ([]byte, error) {
+	if encoding != "base64" {
+		return []byte(body), nil
+	}
+	data, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return nil, fmt.Errorf("解码录制的内容失败: %v", err)
+	}
+	return data, nil
+}
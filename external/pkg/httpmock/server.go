@@ -0,0 +1,252 @@
+// Package httpmock 提供 HTTP 客户端测试工具：声明式的桩服务器 Server，
+// 以及将真实请求录制到 golden 文件并离线回放的 Recorder。
+//
+// 桩服务器的地址可直接作为 DoRequest、NewHttpClientWrapper 的 domain：
+//
+//	srv := httpmock.NewServer(t)
+//	srv.On(http.MethodGet, "/users", httpmock.Query("id", "1")).Reply(http.StatusOK, user).Times(1)
+//	res, err := utils.DoRequest[User](http.MethodGet, srv.URL, "/users", nil, url.Values{"id": {"1"}}, nil)
+//
+// Recorder 作为 Transport 使用：
+//
+//	rec := httpmock.NewRecorder(t, "testdata/users.json")
+//	client := utils.NewHttpClientWrapper("https://api.example.com", utils.WithTransport(rec))
+package httpmock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Matcher 请求匹配条件，body 为已读取的请求体
+type Matcher func(r *http.Request, body []byte) bool
+
+// Method 匹配请求方法
+func Method(method string) Matcher {
+	return func(r *http.Request, _ []byte) bool {
+		return strings.EqualFold(r.Method, method)
+	}
+}
+
+// Path 匹配请求路径，支持 path.Match 的通配符，如 /users/*
+func Path(pattern string) Matcher {
+	return func(r *http.Request, _ []byte) bool {
+		ok, _ := path.Match(pattern, r.URL.Path)
+		return ok
+	}
+}
+
+// Query 匹配查询参数，参数有多个值时任一值相等即可
+func Query(key, value string) Matcher {
+	return func(r *http.Request, _ []byte) bool {
+		for _, v := range r.URL.Query()[key] {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Header 匹配请求头
+func Header(key, value string) Matcher {
+	return func(r *http.Request, _ []byte) bool {
+		return r.Header.Get(key) == value
+	}
+}
+
+// Body 匹配完整的请求体
+func Body(body string) Matcher {
+	return func(_ *http.Request, b []byte) bool {
+		return string(b) == body
+	}
+}
+
+// BodyContains 匹配包含 substr 的请求体
+func BodyContains(substr string) Matcher {
+	return func(_ *http.Request, b []byte) bool {
+		return bytes.Contains(b, []byte(substr))
+	}
+}
+
+// JSONBody 匹配 JSON 请求体，与 v 序列化后的 JSON 语义相等即可，不要求字段顺序和空白一致
+func JSONBody(v any) Matcher {
+	expected, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("序列化期望的请求体失败: %v", err))
+	}
+	return func(_ *http.Request, b []byte) bool {
+		return jsonEqual(expected, b)
+	}
+}
+
+func jsonEqual(a, b []byte) bool {
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	ja, _ := json.Marshal(va)
+	jb, _ := json.Marshal(vb)
+	return bytes.Equal(ja, jb)
+}
+
+// Stub 桩服务器的一条路由期望及其响应
+type Stub struct {
+	desc     string
+	matchers []Matcher
+	status   int
+	header   http.Header
+	body     []byte
+	times    int // 期望的调用次数，0 表示不限制
+
+	mutex sync.Mutex
+	calls int
+}
+
+// Reply 设置响应，body 为 string 或 []byte 时原样返回，其他类型序列化为 JSON
+func (s *Stub) Reply(status int, body any) *Stub {
+	s.status = status
+	switch b := body.(type) {
+	case nil:
+		s.body = nil
+	case string:
+		s.body = []byte(b)
+	case []byte:
+		s.body = b
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			panic(fmt.Sprintf("序列化响应体失败: %v", err))
+		}
+		s.body = data
+		if s.header.Get("Content-Type") == "" {
+			s.header.Set("Content-Type", "application/json")
+		}
+	}
+	return s
+}
+
+// ReplyHeader 设置响应头
+func (s *Stub) ReplyHeader(key, value string) *Stub {
+	s.header.Set(key, value)
+	return s
+}
+
+// Times 期望被调用 n 次，调用 n 次后不再匹配，可为同一路由依次设置多个响应
+func (s *Stub) Times(n int) *Stub {
+	s.times = n
+	return s
+}
+
+// Calls 返回已匹配的请求次数
+func (s *Stub) Calls() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.calls
+}
+
+// take 请求满足条件且未达到期望次数时计数并返回 true
+func (s *Stub) take(r *http.Request, body []byte) bool {
+	for _, m := range s.matchers {
+		if !m(r, body) {
+			return false
+		}
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.times > 0 && s.calls >= s.times {
+		return false
+	}
+	s.calls++
+	return true
+}
+
+// Server 声明式桩服务器，按添加顺序匹配路由，未匹配的请求返回 404 并在测试结束时报错
+type Server struct {
+	*httptest.Server
+	t testing.TB
+
+	mutex     sync.Mutex
+	stubs     []*Stub
+	unmatched []string
+}
+
+// NewServer 启动桩服务器，测试结束时关闭并调用 AssertExpectations
+func NewServer(t testing.TB) *Server {
+	s := &Server{t: t}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	t.Cleanup(func() {
+		s.Close()
+		s.AssertExpectations()
+	})
+	return s
+}
+
+// On 添加路由期望，path 支持 path.Match 的通配符，matchers 为额外的匹配条件，默认响应 200
+func (s *Server) On(method, path string, matchers ...Matcher) *Stub {
+	stub := &Stub{
+		desc:     method + " " + path,
+		matchers: append([]Matcher{Method(method), Path(path)}, matchers...),
+		status:   http.StatusOK,
+		header:   make(http.Header),
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.stubs = append(s.stubs, stub)
+	return stub
+}
+
+// AssertExpectations 检查是否有未匹配的请求，以及设置了 Times 的路由调用次数是否符合期望
+func (s *Server) AssertExpectations() {
+	s.t.Helper()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, req := range s.unmatched {
+		s.t.Errorf("请求未匹配任何路由: %s", req)
+	}
+	for _, stub := range s.stubs {
+		if calls := stub.Calls(); stub.times > 0 && calls != stub.times {
+			s.t.Errorf("%s 被调用 %d 次，期望 %d 次", stub.desc, calls, stub.times)
+		}
+	}
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("读取请求体失败: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	s.mutex.Lock()
+	var stub *Stub
+	for _, candidate := range s.stubs {
+		if candidate.take(r, body) {
+			stub = candidate
+			break
+		}
+	}
+	if stub == nil {
+		s.unmatched = append(s.unmatched, fmt.Sprintf("%s %s %s", r.Method, r.URL.RequestURI(), body))
+	}
+	s.mutex.Unlock()
+
+	if stub == nil {
+		http.Error(w, fmt.Sprintf("httpmock: 请求 %s %s 未匹配任何路由", r.Method, r.URL.RequestURI()), http.StatusNotFound)
+		return
+	}
+	for k, vs := range stub.header {
+		w.Header()[k] = vs
+	}
+	w.WriteHeader(stub.status)
+	_, _ = w.Write(stub.body)
+}
@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock 时钟接口，测试中可替换为 FakeClock 手动推进时间
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker 定时触发器接口
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// syncTicker 需要消费方在处理完一次触发后确认的 Ticker，用于 FakeClock 同步推进
+type syncTicker interface {
+	ack()
+}
+
+// SystemClock 返回基于系统时间的时钟
+func SystemClock() Clock {
+	return systemClock{}
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{ticker: time.NewTicker(d)}
+}
+
+type systemTicker struct {
+	ticker *time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+func (t systemTicker) Stop() {
+	t.ticker.Stop()
+}
+
+// FakeClock 手动推进的时钟，Advance 会同步触发期间到期的所有 Ticker，
+// 并等待消费方（如时间轮）处理完每一次触发后才返回，因此其 Ticker 只能交给支持确认的组件使用。
+// 任务处理函数中不能调用 Advance，否则会死锁。
+type FakeClock struct {
+	mutex   sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+// NewFakeClock 创建从 start 开始的手动时钟
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+// Now 返回当前时间
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// NewTicker 创建在时钟推进时触发的 Ticker
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("ticker interval must be greater than 0")
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	t := &fakeTicker{
+		clock:  c,
+		period: d,
+		next:   c.now.Add(d),
+		c:      make(chan time.Time),
+		acked:  make(chan struct{}),
+		stop:   make(chan struct{}),
+	}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Advance 将时间推进 d，按时间顺序逐次触发期间到期的 Ticker
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	target := c.now.Add(d)
+	c.mutex.Unlock()
+
+	for {
+		c.mutex.Lock()
+		t := c.nextTicker(target)
+		if t == nil {
+			c.now = target
+			c.mutex.Unlock()
+			return
+		}
+		now := t.next
+		c.now = now
+		t.next = t.next.Add(t.period)
+		c.mutex.Unlock()
+
+		t.fire(now)
+	}
+}
+
+// nextTicker 返回 target 之前最早到期的 Ticker，调用方需持有锁
+func (c *FakeClock) nextTicker(target time.Time) *fakeTicker {
+	var earliest *fakeTicker
+	for _, t := range c.tickers {
+		if t.next.After(target) {
+			continue
+		}
+		if earliest == nil || t.next.Before(earliest.next) {
+			earliest = t
+		}
+	}
+	return earliest
+}
+
+func (c *FakeClock) removeTicker(t *fakeTicker) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for i, ticker := range c.tickers {
+		if ticker == t {
+			c.tickers = append(c.tickers[:i], c.tickers[i+1:]...)
+			return
+		}
+	}
+}
+
+type fakeTicker struct {
+	clock    *FakeClock
+	period   time.Duration
+	next     time.Time
+	c        chan time.Time
+	acked    chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.stopOnce.Do(func() {
+		close(t.stop)
+		t.clock.removeTicker(t)
+	})
+}
+
+// fire 发送一次触发并等待消费方确认
+func (t *fakeTicker) fire(now time.Time) {
+	select {
+	case t.c <- now:
+	case <-t.stop:
+		return
+	}
+	select {
+	case <-t.acked:
+	case <-t.stop:
+	}
+}
+
+func (t *fakeTicker) ack() {
+	select {
+	case t.acked <- struct{}{}:
+	case <-t.stop:
+	}
+}
@@ -0,0 +1,450 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ClusterTask 集群定时任务，持久化在共享存储中，由当前 leader 实例调度
+type ClusterTask struct {
+	ID      string    // 任务唯一标识，相同ID重复添加会覆盖
+	Handler string    // 通过 Handle 注册的处理函数名
+	Payload []byte    // 任务数据
+	DueAt   time.Time // 预期触发时间
+}
+
+// ClusterTaskHandler 集群任务处理函数
+type ClusterTaskHandler func(ctx context.Context, payload []byte) error
+
+// ClusterBackend 集群协调后端，提供租约和共享任务存储
+type ClusterBackend interface {
+	// AcquireLease 获取或续约名为 name 的租约，租约空闲、已过期或已由 owner 持有时成功
+	AcquireLease(ctx context.Context, name, owner string, ttl time.Duration) (bool, error)
+	// ReleaseLease 释放 owner 持有的租约
+	ReleaseLease(ctx context.Context, name, owner string) error
+	// SaveTask 保存任务，ID 相同则覆盖
+	SaveTask(ctx context.Context, task ClusterTask) error
+	// ListTasks 返回所有未完成且未被认领（或认领已过期）的任务
+	ListTasks(ctx context.Context) ([]ClusterTask, error)
+	// ClaimTask 仅当 owner 仍持有有效租约、任务未被删除或改期且未被认领（或认领已过期）时，
+	// 将任务标记为由 owner 认领 ttl 时间并返回 true，认领期间任务不会被其他实例执行
+	ClaimTask(ctx context.Context, name, owner string, task ClusterTask, ttl time.Duration) (bool, error)
+	// CompleteTask 任务执行成功后删除 owner 认领的任务，任务已被改期或由其他实例重新认领时忽略
+	CompleteTask(ctx context.Context, owner string, task ClusterTask) error
+	// DeleteTask 删除任务
+	DeleteTask(ctx context.Context, id string) error
+}
+
+// ClusterOption 集群时间轮配置选项
+type ClusterOption func(*ClusterTimingWheel)
+
+// WithLeaseTTL 设置租约有效期，leader 宕机后最长经过该时间由其他实例接管
+func WithLeaseTTL(ttl time.Duration) ClusterOption {
+	return func(c *ClusterTimingWheel) {
+		c.leaseTTL = ttl
+	}
+}
+
+// WithSyncInterval 设置续约和同步任务的间隔，需小于租约有效期
+func WithSyncInterval(interval time.Duration) ClusterOption {
+	return func(c *ClusterTimingWheel) {
+		c.syncInterval = interval
+	}
+}
+
+// WithClaimTTL 设置任务认领的有效期，默认1分钟。处理函数的 ctx 在认领过期时结束，
+// 认领过期前未执行成功的任务会被重新调度，因此处理函数的执行时间应小于该值
+func WithClaimTTL(ttl time.Duration) ClusterOption {
+	return func(c *ClusterTimingWheel) {
+		c.claimTTL = ttl
+	}
+}
+
+// WithInstanceID 设置实例标识，默认由主机名、进程号和随机数组成
+func WithInstanceID(id string) ClusterOption {
+	return func(c *ClusterTimingWheel) {
+		c.owner = id
+	}
+}
+
+// ClusterTimingWheel 集群定时器，多个实例共享同一后端，通过租约选出 leader，
+// 仅 leader 将共享存储中的任务调度到本地时间轮，触发前通过 ClaimTask 认领，认领期间不会被其他实例执行。
+// leader 宕机后租约过期，其他实例在下一次同步时接管并调度全部未完成的任务。
+// 任务执行成功后才从共享存储删除；处理函数返回错误、超过认领有效期或执行过程中实例宕机时，
+// 认领过期后任务会被重新调度。因此任务至少执行一次，少数情况下可能重复执行，处理函数应保证幂等。
+type ClusterTimingWheel struct {
+	name         string
+	owner        string
+	backend      ClusterBackend
+	wheel        *TimingWheel
+	leaseTTL     time.Duration
+	claimTTL     time.Duration
+	syncInterval time.Duration
+
+	mutex     sync.Mutex
+	handlers  map[string]ClusterTaskHandler
+	leader    bool
+	term      uint64               // 每次成为 leader 自增，旧任期调度的任务触发时直接忽略
+	scheduled map[string]time.Time // 当前任期已调度到本地时间轮的任务及其触发时间
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewClusterTimingWheel 创建集群定时器，name 为集群名（同时作为租约名），wheel 的生命周期由调用方管理
+func NewClusterTimingWheel(name string, backend ClusterBackend, wheel *TimingWheel, opts ...ClusterOption) *ClusterTimingWheel {
+	c := &ClusterTimingWheel{
+		name:      name,
+		owner:     defaultInstanceID(),
+		backend:   backend,
+		wheel:     wheel,
+		leaseTTL:  15 * time.Second,
+		claimTTL:  time.Minute,
+		handlers:  make(map[string]ClusterTaskHandler),
+		scheduled: make(map[string]time.Time),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.syncInterval <= 0 {
+		c.syncInterval = c.leaseTTL / 3
+	}
+	return c
+}
+
+func defaultInstanceID() string {
+	hostname, _ := os.Hostname()
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), hex.EncodeToString(b))
+}
+
+// InstanceID 返回实例标识
+func (c *ClusterTimingWheel) InstanceID() string {
+	return c.owner
+}
+
+// IsLeader 返回当前实例是否为 leader
+func (c *ClusterTimingWheel) IsLeader() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.leader
+}
+
+// Handle 注册任务处理函数，所有实例需注册相同的处理函数
+func (c *ClusterTimingWheel) Handle(name string, handler ClusterTaskHandler) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.handlers[name] = handler
+}
+
+// AddTask 添加集群任务，delay 后由 leader 触发。非 leader 实例添加的任务在 leader 下一次同步时被调度
+func (c *ClusterTimingWheel) AddTask(ctx context.Context, id, handler string, payload []byte, delay time.Duration) error {
+	if delay < 0 {
+		return fmt.Errorf("delay不能为负数")
+	}
+	t := ClusterTask{
+		ID:      id,
+		Handler: handler,
+		Payload: payload,
+		// 截断到毫秒，与持久化后端的精度保持一致
+		DueAt: c.wheel.clock.Now().Add(delay).Truncate(time.Millisecond),
+	}
+	if err := c.backend.SaveTask(ctx, t); err != nil {
+		return fmt.Errorf("保存集群任务失败: %v", err)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.leader {
+		return c.scheduleLocked(t)
+	}
+	return nil
+}
+
+// CancelTask 取消集群任务
+func (c *ClusterTimingWheel) CancelTask(ctx context.Context, id string) error {
+	if err := c.backend.DeleteTask(ctx, id); err != nil {
+		return fmt.Errorf("删除集群任务失败: %v", err)
+	}
+	c.mutex.Lock()
+	delete(c.scheduled, id)
+	c.mutex.Unlock()
+	return nil
+}
+
+// Start 启动后台同步，立即尝试获取租约，之后按同步间隔续约并加载新任务
+func (c *ClusterTimingWheel) Start() error {
+	c.mutex.Lock()
+	if c.done != nil {
+		c.mutex.Unlock()
+		return fmt.Errorf("集群定时器已经在运行")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	c.cancel, c.done = cancel, done
+	ticker := c.wheel.clock.NewTicker(c.syncInterval)
+	c.mutex.Unlock()
+
+	if err := c.Sync(ctx); err != nil {
+		GetLogger().Warnf("集群定时器同步失败: %v", err)
+	}
+	go c.loop(ctx, done, ticker)
+	return nil
+}
+
+func (c *ClusterTimingWheel) loop(ctx context.Context, done chan struct{}, ticker Ticker) {
+	defer close(done)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			if err := c.Sync(ctx); err != nil {
+				GetLogger().Warnf("集群定时器同步失败: %v", err)
+			}
+			if st, ok := ticker.(syncTicker); ok {
+				st.ack()
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Stop 停止后台同步并释放租约，以便其他实例立即接管
+func (c *ClusterTimingWheel) Stop(ctx context.Context) error {
+	c.mutex.Lock()
+	if c.done == nil {
+		c.mutex.Unlock()
+		return nil
+	}
+	c.cancel()
+	done := c.done
+	c.done = nil
+	c.mutex.Unlock()
+	<-done
+
+	c.resign()
+	return c.backend.ReleaseLease(ctx, c.name, c.owner)
+}
+
+// Sync 获取或续约租约，作为 leader 时将共享存储中尚未调度的任务加入本地时间轮
+func (c *ClusterTimingWheel) Sync(ctx context.Context) error {
+	ok, err := c.backend.AcquireLease(ctx, c.name, c.owner, c.leaseTTL)
+	if err != nil {
+		// 无法确认租约时按失去领导权处理，避免与新 leader 同时调度
+		c.resign()
+		return fmt.Errorf("获取租约失败: %v", err)
+	}
+	if !ok {
+		c.resign()
+		return nil
+	}
+
+	c.mutex.Lock()
+	if !c.leader {
+		c.leader = true
+		c.term++
+		c.scheduled = make(map[string]time.Time)
+		GetLogger().Infof("实例 %s 成为集群 %s 的leader", c.owner, c.name)
+	}
+	c.mutex.Unlock()
+
+	tasks, err := c.backend.ListTasks(ctx)
+	if err != nil {
+		return fmt.Errorf("加载集群任务失败: %v", err)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.leader {
+		return nil
+	}
+	for _, t := range tasks {
+		if due, ok := c.scheduled[t.ID]; ok && due.Equal(t.DueAt) {
+			continue
+		}
+		if err = c.scheduleLocked(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resign 放弃领导权，本地时间轮中已调度的任务在触发时因任期变化被忽略
+func (c *ClusterTimingWheel) resign() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.leader {
+		c.leader = false
+		c.term++
+		c.scheduled = make(map[string]time.Time)
+		GetLogger().Infof("实例 %s 不再是集群 %s 的leader", c.owner, c.name)
+	}
+}
+
+// scheduleLocked 将任务加入本地时间轮，调用方需持有锁
+func (c *ClusterTimingWheel) scheduleLocked(t ClusterTask) error {
+	delay := t.DueAt.Sub(c.wheel.clock.Now())
+	if delay < 0 {
+		delay = 0
+	}
+	term := c.term
+	if err := c.wheel.AddErrTask(t, func(data any, tc TaskContext) error {
+		return c.fire(tc.(TaskRunContext).Context(), term, data.(ClusterTask))
+	}, delay); err != nil {
+		return fmt.Errorf("调度集群任务失败: %v", err)
+	}
+	c.scheduled[t.ID] = t.DueAt
+	return nil
+}
+
+// fire 认领并执行任务，执行成功后删除任务，任期已变化或任务已被改期时忽略。
+// 处理函数未注册时不认领，任务留在共享存储中，下次同步时重新调度或由其他实例执行。
+func (c *ClusterTimingWheel) fire(ctx context.Context, term uint64, t ClusterTask) error {
+	c.mutex.Lock()
+	due, ok := c.scheduled[t.ID]
+	if term != c.term || !ok || !due.Equal(t.DueAt) {
+		c.mutex.Unlock()
+		return nil
+	}
+	delete(c.scheduled, t.ID)
+	handler := c.handlers[t.Handler]
+	c.mutex.Unlock()
+
+	if handler == nil {
+		return fmt.Errorf("集群任务 %s 的处理函数 %s 未注册", t.ID, t.Handler)
+	}
+	claimed, err := c.backend.ClaimTask(ctx, c.name, c.owner, t, c.claimTTL)
+	if err != nil {
+		return fmt.Errorf("认领集群任务 %s 失败: %v", t.ID, err)
+	}
+	if !claimed {
+		return nil
+	}
+
+	handlerCtx, cancel := context.WithTimeout(ctx, c.claimTTL)
+	defer cancel()
+	if err = handler(handlerCtx, t.Payload); err != nil {
+		// 保留任务，认领过期后重新调度
+		return err
+	}
+	if err = c.backend.CompleteTask(ctx, c.owner, t); err != nil {
+		return fmt.Errorf("完成集群任务 %s 失败: %v", t.ID, err)
+	}
+	return nil
+}
+
+type memoryLease struct {
+	owner     string
+	expiresAt time.Time
+}
+
+type memoryTask struct {
+	task           ClusterTask
+	claimedBy      string
+	claimExpiresAt time.Time
+}
+
+// MemoryClusterBackend 进程内集群后端，用于测试或单进程内多个实例
+type MemoryClusterBackend struct {
+	mutex  sync.Mutex
+	clock  Clock
+	leases map[string]memoryLease
+	tasks  map[string]memoryTask
+}
+
+// NewMemoryClusterBackend 创建进程内集群后端，clock 用于判断租约和认领是否过期
+func NewMemoryClusterBackend(clock Clock) *MemoryClusterBackend {
+	return &MemoryClusterBackend{
+		clock:  clock,
+		leases: make(map[string]memoryLease),
+		tasks:  make(map[string]memoryTask),
+	}
+}
+
+func (b *MemoryClusterBackend) AcquireLease(_ context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := b.clock.Now()
+	if l, ok := b.leases[name]; ok && l.owner != owner && now.Before(l.expiresAt) {
+		return false, nil
+	}
+	b.leases[name] = memoryLease{owner: owner, expiresAt: now.Add(ttl)}
+	return true, nil
+}
+
+func (b *MemoryClusterBackend) ReleaseLease(_ context.Context, name, owner string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if l, ok := b.leases[name]; ok && l.owner == owner {
+		delete(b.leases, name)
+	}
+	return nil
+}
+
+func (b *MemoryClusterBackend) SaveTask(_ context.Context, task ClusterTask) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.tasks[task.ID] = memoryTask{task: task}
+	return nil
+}
+
+func (b *MemoryClusterBackend) ListTasks(_ context.Context) ([]ClusterTask, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	now := b.clock.Now()
+	tasks := make([]ClusterTask, 0, len(b.tasks))
+	for _, t := range b.tasks {
+		if now.Before(t.claimExpiresAt) {
+			continue
+		}
+		tasks = append(tasks, t.task)
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].DueAt.Before(tasks[j].DueAt)
+	})
+	return tasks, nil
+}
+
+func (b *MemoryClusterBackend) ClaimTask(_ context.Context, name, owner string, task ClusterTask, ttl time.Duration) (bool, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := b.clock.Now()
+	l, ok := b.leases[name]
+	if !ok || l.owner != owner || !now.Before(l.expiresAt) {
+		return false, nil
+	}
+	stored, ok := b.tasks[task.ID]
+	if !ok || !stored.task.DueAt.Equal(task.DueAt) || now.Before(stored.claimExpiresAt) {
+		return false, nil
+	}
+	stored.claimedBy, stored.claimExpiresAt = owner, now.Add(ttl)
+	b.tasks[task.ID] = stored
+	return true, nil
+}
+
+func (b *MemoryClusterBackend) CompleteTask(_ context.Context, owner string, task ClusterTask) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if stored, ok := b.tasks[task.ID]; ok && stored.claimedBy == owner && stored.task.DueAt.Equal(task.DueAt) {
+		delete(b.tasks, task.ID)
+	}
+	return nil
+}
+
+func (b *MemoryClusterBackend) DeleteTask(_ context.Context, id string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	delete(b.tasks, id)
+	return nil
+}
@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// ClusterLease 集群租约表
+type ClusterLease struct {
+	Name      string `gorm:"primaryKey;size:128"`
+	Owner     string `gorm:"size:128;not null"`
+	ExpiresAt int64  `gorm:"not null"` // 过期时间，毫秒时间戳
+}
+
+// TableName 表名
+func (ClusterLease) TableName() string {
+	return "cluster_leases"
+}
+
+// ClusterTaskRecord 集群任务表
+type ClusterTaskRecord struct {
+	ID             string `gorm:"primaryKey;size:128"`
+	Handler        string `gorm:"size:128;not null"`
+	Payload        []byte
+	DueAt          int64  `gorm:"index;not null"`               // 预期触发时间，毫秒时间戳
+	ClaimedBy      string `gorm:"size:128;not null;default:''"` // 认领任务的实例
+	ClaimExpiresAt int64  `gorm:"index;not null;default:0"`     // 认领过期时间，毫秒时间戳，未认领时为 0
+}
+
+// TableName 表名
+func (ClusterTaskRecord) TableName() string {
+	return "cluster_tasks"
+}
+
+// GormClusterBackend 基于 gorm 的集群后端，各实例共享同一数据库。
+// 租约过期时间由实例本地时钟计算，各实例间时钟偏差需远小于租约有效期。
+type GormClusterBackend struct {
+	db    *gorm.DB
+	clock Clock
+}
+
+// NewGormClusterBackend 创建基于 gorm 的集群后端并自动迁移表结构
+func NewGormClusterBackend(db *gorm.DB, clock Clock) (*GormClusterBackend, error) {
+	if err := db.AutoMigrate(&ClusterLease{}, &ClusterTaskRecord{}); err != nil {
+		return nil, fmt.Errorf("迁移集群表结构失败: %v", err)
+	}
+	return &GormClusterBackend{db: db, clock: clock}, nil
+}
+
+func (b *GormClusterBackend) AcquireLease(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	now := b.clock.Now()
+	expiresAt := now.Add(ttl).UnixMilli()
+
+	// 续约自己的租约或抢占已过期的租约
+	res := b.db.WithContext(ctx).Model(&ClusterLease{}).
+		Where("name = ? AND (owner = ? OR expires_at <= ?)", name, owner, now.UnixMilli()).
+		Updates(map[string]any{"owner": owner, "expires_at": expiresAt})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected > 0 {
+		return true, nil
+	}
+
+	// 租约不存在时创建，并发创建时只有一个实例成功
+	res = b.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&ClusterLease{Name: name, Owner: owner, ExpiresAt: expiresAt})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (b *GormClusterBackend) ReleaseLease(ctx context.Context, name, owner string) error {
+	return b.db.WithContext(ctx).
+		Where("name = ? AND owner = ?", name, owner).
+		Delete(&ClusterLease{}).Error
+}
+
+func (b *GormClusterBackend) SaveTask(ctx context.Context, task ClusterTask) error {
+	// 覆盖已有任务时一并清除认领
+	return b.db.WithContext(ctx).Save(&ClusterTaskRecord{
+		ID:      task.ID,
+		Handler: task.Handler,
+		Payload: task.Payload,
+		DueAt:   task.DueAt.UnixMilli(),
+	}).Error
+}
+
+func (b *GormClusterBackend) ListTasks(ctx context.Context) ([]ClusterTask, error) {
+	var records []ClusterTaskRecord
+	err := b.db.WithContext(ctx).Where("claim_expires_at <= ?", b.clock.Now().UnixMilli()).
+		Order("due_at").Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+	tasks := make([]ClusterTask, 0, len(records))
+	for _, r := range records {
+		tasks = append(tasks, ClusterTask{
+			ID:      r.ID,
+			Handler: r.Handler,
+			Payload: r.Payload,
+			DueAt:   time.UnixMilli(r.DueAt),
+		})
+	}
+	return tasks, nil
+}
+
+func (b *GormClusterBackend) ClaimTask(ctx context.Context, name, owner string, task ClusterTask, ttl time.Duration) (bool, error) {
+	claimed := false
+	err := b.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 锁定租约行，防止认领过程中租约被其他实例抢占
+		var lease ClusterLease
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("name = ?", name).Take(&lease).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		now := b.clock.Now()
+		if lease.Owner != owner || lease.ExpiresAt <= now.UnixMilli() {
+			return nil
+		}
+
+		res := tx.Model(&ClusterTaskRecord{}).
+			Where("id = ? AND due_at = ? AND claim_expires_at <= ?", task.ID, task.DueAt.UnixMilli(), now.UnixMilli()).
+			Updates(map[string]any{"claimed_by": owner, "claim_expires_at": now.Add(ttl).UnixMilli()})
+		if res.Error != nil {
+			return res.Error
+		}
+		claimed = res.RowsAffected > 0
+		return nil
+	})
+	return claimed, err
+}
+
+func (b *GormClusterBackend) CompleteTask(ctx context.Context, owner string, task ClusterTask) error {
+	return b.db.WithContext(ctx).
+		Where("id = ? AND due_at = ? AND claimed_by = ?", task.ID, task.DueAt.UnixMilli(), owner).
+		Delete(&ClusterTaskRecord{}).Error
+}
+
+func (b *GormClusterBackend) DeleteTask(ctx context.Context, id string) error {
+	return b.db.WithContext(ctx).Where("id = ?", id).Delete(&ClusterTaskRecord{}).Error
+}
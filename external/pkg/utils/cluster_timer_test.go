@@ -0,0 +1,282 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClusterTimingWheel(t *testing.T) {
+	ctx := context.Background()
+	clock := NewFakeClock(time.Now())
+	backend := NewMemoryClusterBackend(clock)
+
+	fired := make(map[string]int)
+	newInstance := func(id string) (*ClusterTimingWheel, *TimingWheel) {
+		tw := NewTimingWheel(1, 60, WithClock(clock))
+		if err := tw.Start(); err != nil {
+			t.Fatal(err)
+		}
+		c := NewClusterTimingWheel("orders", backend, tw, WithInstanceID(id), WithLeaseTTL(10*time.Second))
+		c.Handle("expire", func(ctx context.Context, payload []byte) error {
+			fired[string(payload)+"@"+id]++
+			return nil
+		})
+		return c, tw
+	}
+	a, wa := newInstance("a")
+	b, wb := newInstance("b")
+	defer wb.Stop()
+
+	for _, c := range []*ClusterTimingWheel{a, b} {
+		if err := c.Sync(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatal("应由先同步的实例成为leader")
+	}
+
+	// 非 leader 添加的任务由 leader 调度，且只触发一次
+	if err := b.AddTask(ctx, "order-1", "expire", []byte("order-1"), 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	_ = a.Sync(ctx)
+	_ = b.Sync(ctx)
+	clock.Advance(6 * time.Second)
+	if fired["order-1@a"] != 1 || fired["order-1@b"] != 0 {
+		t.Fatalf("任务触发情况不正确: %v", fired)
+	}
+
+	// leader 宕机后由其他实例接管未触发的任务
+	if err := a.AddTask(ctx, "order-2", "expire", []byte("order-2"), 20*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	wa.Stop()
+	clock.Advance(11 * time.Second)
+	if err := b.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !b.IsLeader() {
+		t.Fatal("租约过期后未完成leader切换")
+	}
+	clock.Advance(10 * time.Second)
+	if fired["order-2@b"] != 1 || fired["order-2@a"] != 0 {
+		t.Fatalf("接管后任务触发情况不正确: %v", fired)
+	}
+}
+
+func TestClusterTimingWheelMissingHandler(t *testing.T) {
+	ctx := context.Background()
+	clock := NewFakeClock(time.Now())
+	backend := NewMemoryClusterBackend(clock)
+
+	tw := NewTimingWheel(1, 60, WithClock(clock))
+	if err := tw.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer tw.Stop()
+	c := NewClusterTimingWheel("orders", backend, tw, WithInstanceID("a"), WithLeaseTTL(time.Minute))
+	if err := c.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddTask(ctx, "order-1", "expire", []byte("order-1"), 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// 处理函数未注册时不认领，任务保留在共享存储中
+	clock.Advance(6 * time.Second)
+	tasks, _ := backend.ListTasks(ctx)
+	if len(tasks) != 1 || tasks[0].ID != "order-1" {
+		t.Fatalf("处理函数未注册时任务不应被认领: %v", tasks)
+	}
+
+	// 注册处理函数后重新同步即可触发
+	fired := 0
+	c.Handle("expire", func(ctx context.Context, payload []byte) error {
+		fired++
+		return nil
+	})
+	if err := c.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Second)
+	if fired != 1 {
+		t.Fatalf("期望任务触发1次，实际 %d 次", fired)
+	}
+	if tasks, _ = backend.ListTasks(ctx); len(tasks) != 0 {
+		t.Fatalf("任务触发后应从共享存储删除: %v", tasks)
+	}
+}
+
+func TestClusterTimingWheelHandlerFailure(t *testing.T) {
+	ctx := context.Background()
+	clock := NewFakeClock(time.Now())
+	backend := NewMemoryClusterBackend(clock)
+
+	tw := NewTimingWheel(1, 60, WithClock(clock))
+	if err := tw.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer tw.Stop()
+	c := NewClusterTimingWheel("orders", backend, tw, WithInstanceID("a"), WithLeaseTTL(time.Minute), WithClaimTTL(10*time.Second))
+	calls := 0
+	c.Handle("expire", func(ctx context.Context, payload []byte) error {
+		calls++
+		if calls == 1 {
+			return errors.New("下游不可用")
+		}
+		return nil
+	})
+	if err := c.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddTask(ctx, "order-1", "expire", []byte("order-1"), 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// 执行失败时保留任务，认领期间不会重新调度
+	clock.Advance(6 * time.Second)
+	if err := c.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(2 * time.Second)
+	if calls != 1 {
+		t.Fatalf("认领期间不应重新执行，执行 %d 次", calls)
+	}
+
+	// 认领过期后重新调度并执行成功，成功后删除任务
+	clock.Advance(8 * time.Second)
+	if err := c.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Second)
+	if calls != 2 {
+		t.Fatalf("认领过期后应重新执行，执行 %d 次", calls)
+	}
+	clock.Advance(11 * time.Second)
+	if tasks, _ := backend.ListTasks(ctx); len(tasks) != 0 {
+		t.Fatalf("执行成功后任务应被删除: %v", tasks)
+	}
+}
+
+func TestClusterBackends(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		testClusterBackend(t, NewMemoryClusterBackend(clock), clock)
+	})
+	t.Run("gorm", func(t *testing.T) {
+		db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "cluster.db")), &gorm.Config{
+			Logger: logger.Default.LogMode(logger.Silent),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		clock := NewFakeClock(time.Now())
+		backend, err := NewGormClusterBackend(db, clock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testClusterBackend(t, backend, clock)
+	})
+}
+
+// testClusterBackend 校验 ClusterBackend 的租约和任务认领语义
+func testClusterBackend(t *testing.T, backend ClusterBackend, clock *FakeClock) {
+	ctx := context.Background()
+	list := func() []ClusterTask {
+		t.Helper()
+		tasks, err := backend.ListTasks(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tasks
+	}
+	claim := func(owner string, task ClusterTask, want bool, msg string) {
+		t.Helper()
+		if ok, err := backend.ClaimTask(ctx, "orders", owner, task, 5*time.Second); err != nil || ok != want {
+			t.Fatalf("%s: %v %v", msg, ok, err)
+		}
+	}
+
+	if ok, err := backend.AcquireLease(ctx, "orders", "a", 10*time.Second); err != nil || !ok {
+		t.Fatalf("a 获取租约失败: %v %v", ok, err)
+	}
+	if ok, err := backend.AcquireLease(ctx, "orders", "b", 10*time.Second); err != nil || ok {
+		t.Fatalf("租约有效期内 b 不应获取租约: %v %v", ok, err)
+	}
+
+	task := ClusterTask{ID: "order-1", Handler: "expire", Payload: []byte("order-1"), DueAt: clock.Now().Add(5 * time.Second).Truncate(time.Millisecond)}
+	if err := backend.SaveTask(ctx, task); err != nil {
+		t.Fatal(err)
+	}
+	if tasks := list(); len(tasks) != 1 || string(tasks[0].Payload) != "order-1" || !tasks[0].DueAt.Equal(task.DueAt) {
+		t.Fatalf("读取任务不正确: %v", tasks)
+	}
+
+	// 未持有租约或任务已改期时不能认领
+	claim("b", task, false, "未持有租约的实例不应认领任务")
+	stale := task
+	stale.DueAt = task.DueAt.Add(-time.Second)
+	claim("a", stale, false, "已改期的任务不应被认领")
+
+	// 认领期间任务不会被再次认领或列出
+	claim("a", task, true, "leader 认领任务失败")
+	claim("a", task, false, "认领期间任务不应被重复认领")
+	if tasks := list(); len(tasks) != 0 {
+		t.Fatalf("认领期间不应列出任务: %v", tasks)
+	}
+
+	// 认领过期未完成（如执行中宕机）时重新列出，可再次认领
+	clock.Advance(6 * time.Second)
+	if tasks := list(); len(tasks) != 1 {
+		t.Fatalf("认领过期后应重新列出任务: %v", tasks)
+	}
+	claim("a", task, true, "认领过期后应能再次认领")
+
+	// 只有认领者能完成任务，任务改期后旧的认领失效
+	if err := backend.CompleteTask(ctx, "b", task); err != nil {
+		t.Fatal(err)
+	}
+	moved := task
+	moved.DueAt = task.DueAt.Add(time.Minute)
+	if err := backend.SaveTask(ctx, moved); err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.CompleteTask(ctx, "a", task); err != nil {
+		t.Fatal(err)
+	}
+	if tasks := list(); len(tasks) != 1 || !tasks[0].DueAt.Equal(moved.DueAt) {
+		t.Fatalf("改期后的任务不应被旧的认领完成: %v", tasks)
+	}
+	claim("a", moved, true, "leader 认领改期后的任务失败")
+	if err := backend.CompleteTask(ctx, "a", moved); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(6 * time.Second)
+	if tasks := list(); len(tasks) != 0 {
+		t.Fatalf("完成后任务应被删除: %v", tasks)
+	}
+
+	// 租约过期后原 leader 不能认领，新 leader 可以认领
+	if err := backend.SaveTask(ctx, task); err != nil {
+		t.Fatal(err)
+	}
+	claim("a", task, false, "租约过期后不应认领任务")
+	if ok, err := backend.AcquireLease(ctx, "orders", "b", 10*time.Second); err != nil || !ok {
+		t.Fatalf("租约过期后 b 应获取租约: %v %v", ok, err)
+	}
+	claim("b", task, true, "新 leader 认领任务失败")
+
+	if err := backend.ReleaseLease(ctx, "orders", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := backend.AcquireLease(ctx, "orders", "a", 10*time.Second); err != nil || !ok {
+		t.Fatalf("租约释放后 a 应获取租约: %v %v", ok, err)
+	}
+}
@@ -3,7 +3,6 @@ package utils
 import (
 	"errors"
 	"fmt"
-	"gopkg.in/yaml.v3"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -12,10 +11,18 @@ import (
 
 // ConfigLoader 配置加载器
 type ConfigLoader[T any] struct {
-	config     T
-	configPath string
-	mutex      sync.RWMutex
-	watchers   []ConfigWatcher
+	config         T
+	defaults       T // 默认配置的副本，加载和重新加载时以其深拷贝为基础解析配置文件
+	configPath     string
+	mutex          sync.RWMutex
+	watchers       []ConfigWatcher
+	reloadWatchers []func(old, new T)
+	validator      func(T) error
+
+	envEnabled bool
+	envPrefix  string
+	flagValues map[string]flagValue   // 已设置的命令行参数，键为 yaml 路径
+	sources    map[string]FieldSource // 每个配置项的来源
 }
 
 // ConfigWatcher 配置变更监听器
@@ -25,13 +32,16 @@ type ConfigWatcher interface {
 
 // NewConfigLoader 创建新的配置加载器
 func NewConfigLoader[T any](defaultConfig T) *ConfigLoader[T] {
+	// 加载配置文件时会修改 config 中引用类型的字段，因此深拷贝保存默认配置
 	return &ConfigLoader[T]{
-		config:   defaultConfig,
-		watchers: make([]ConfigWatcher, 0),
+		config:     defaultConfig,
+		defaults:   deepCopy(defaultConfig),
+		watchers:   make([]ConfigWatcher, 0),
+		flagValues: make(map[string]flagValue),
 	}
 }
 
-// LoadYamlConfigFile 加载YAML配置文件
+// LoadYamlConfigFile 加载配置文件，支持 YAML、JSON、TOML 和 .env 格式，见 RegisterConfigFormat
 func LoadYamlConfigFile[T any](paths ...string) (cfg T, err error) {
 	loader := NewConfigLoader(cfg)
 	return loader.LoadFromPaths(paths...)
@@ -51,7 +61,9 @@ func (l *ConfigLoader[T]) LoadFromPaths(paths ...string) (T, error) {
 			lastErr = err
 			continue
 		}
+		l.mutex.Lock()
 		l.configPath = path
+		l.mutex.Unlock()
 		l.notifyWatchers()
 		return l.GetConfig(), nil
 	}
@@ -64,9 +76,10 @@ func (l *ConfigLoader[T]) LoadFromPaths(paths ...string) (T, error) {
 
 // loadFromPath 从单个路径加载配置
 func (l *ConfigLoader[T]) loadFromPath(path string) error {
-	// 验证文件扩展名
-	if ext := filepath.Ext(path); ext != ".yml" && ext != ".yaml" {
-		return fmt.Errorf("不支持的文件类型: %s", ext)
+	// 根据文件扩展名选择配置文件格式
+	format, ok := GetConfigFormat(path)
+	if !ok {
+		return fmt.Errorf("不支持的文件类型: %s", filepath.Ext(path))
 	}
 
 	// 检查文件是否存在
@@ -79,12 +92,21 @@ func (l *ConfigLoader[T]) loadFromPath(path string) error {
 		return fmt.Errorf("读取配置文件失败: %v", err)
 	}
 
+	// 以默认配置为基础解析，不与当前配置共享引用类型的字段
+	config, err := l.parse(path, file)
+	if err != nil {
+		return err
+	}
+
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
 
-	if err = yaml.Unmarshal(file, &l.config); err != nil {
-		return fmt.Errorf("解析YAML失败: %v", err)
+	// 依次以环境变量和命令行参数覆盖
+	sources, err := overlay(&config, path, file, format, l.overlaySettings())
+	if err != nil {
+		return err
 	}
+	l.config, l.sources = config, sources
 
 	GetLogger().Infof("成功加载配置文件: %s", path)
 	return nil
@@ -118,41 +140,103 @@ func (l *ConfigLoader[T]) RemoveWatcher(watcher ConfigWatcher) {
 
 // notifyWatchers 通知所有监听器配置已变更
 func (l *ConfigLoader[T]) notifyWatchers() {
-	for _, watcher := range l.watchers {
-		watcher.OnConfigChange(l.config)
+	l.mutex.RLock()
+	config := l.config
+	watchers := append([]ConfigWatcher(nil), l.watchers...)
+	l.mutex.RUnlock()
+
+	for _, watcher := range watchers {
+		watcher.OnConfigChange(config)
 	}
 }
 
-// SaveConfig 保存配置到文件
+// SaveConfig 按配置文件的格式保存配置到文件，来源为环境变量或命令行参数的配置项保留配置文件中的值
 func (l *ConfigLoader[T]) SaveConfig() error {
-	if l.configPath == "" {
+	l.mutex.RLock()
+	configPath := l.configPath
+	config, sources := l.config, l.sources
+	l.mutex.RUnlock()
+
+	if configPath == "" {
 		return errors.New("未设置配置文件路径")
 	}
 
+	base, err := l.fileLayer(configPath)
+	if err != nil {
+		return err
+	}
+	_, err = l.writeConfig(configPath, withoutOverrides(config, base, sources))
+	return err
+}
+
+// UpdateConfig 以默认配置和配置文件中的配置为基础调用 updater 并保存到配置文件，
+// 再应用环境变量和命令行参数作为当前配置，来源为环境变量或命令行参数的配置项不会写入配置文件
+func (l *ConfigLoader[T]) UpdateConfig(updater func(T) T) error {
+	configPath := l.path()
+	if configPath == "" {
+		l.mutex.Lock()
+		l.config = updater(l.config)
+		l.mutex.Unlock()
+		l.notifyWatchers()
+		return errors.New("未设置配置文件路径")
+	}
+
+	base, err := l.fileLayer(configPath)
+	if err != nil {
+		return err
+	}
 	l.mutex.RLock()
-	data, err := yaml.Marshal(l.config)
+	sources := l.sources
+	settings := l.overlaySettings()
 	l.mutex.RUnlock()
 
+	config := withoutOverrides(updater(base), base, sources)
+	data, err := l.writeConfig(configPath, config)
 	if err != nil {
-		return fmt.Errorf("序列化配置失败: %v", err)
+		return err
 	}
-
-	if err = os.WriteFile(l.configPath, data, 0644); err != nil {
-		return fmt.Errorf("写入配置文件失败: %v", err)
+	format, _ := GetConfigFormat(configPath)
+	if sources, err = overlay(&config, configPath, data, format, settings); err != nil {
+		return err
 	}
 
-	GetLogger().Infof("成功保存配置到: %s", l.configPath)
+	l.mutex.Lock()
+	l.config, l.sources = config, sources
+	l.mutex.Unlock()
+	l.notifyWatchers()
 	return nil
 }
 
-// UpdateConfig 更新配置
-func (l *ConfigLoader[T]) UpdateConfig(updater func(T) T) error {
-	l.mutex.Lock()
-	l.config = updater(l.config)
-	l.mutex.Unlock()
+// fileLayer 从磁盘重新解析配置文件，返回只包含默认配置和配置文件的配置，文件不存在时返回默认配置
+func (l *ConfigLoader[T]) fileLayer(path string) (T, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l.copyDefaults(), nil
+	}
+	if err != nil {
+		var config T
+		return config, fmt.Errorf("读取配置文件失败: %v", err)
+	}
+	return l.parse(path, data)
+}
 
-	l.notifyWatchers()
-	return l.SaveConfig()
+// writeConfig 按 path 的格式序列化 config 并写入文件，返回写入的内容
+func (l *ConfigLoader[T]) writeConfig(path string, config T) ([]byte, error) {
+	format, ok := GetConfigFormat(path)
+	if !ok {
+		return nil, fmt.Errorf("不支持的文件类型: %s", filepath.Ext(path))
+	}
+	data, err := format.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("序列化配置失败: %v", err)
+	}
+
+	if err = os.WriteFile(path, data, 0644); err != nil {
+		return nil, fmt.Errorf("写入配置文件失败: %v", err)
+	}
+
+	GetLogger().Infof("成功保存配置到: %s", path)
+	return data, nil
 }
 
 // Validate 验证配置
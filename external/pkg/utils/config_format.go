@@ -0,0 +1,266 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ConfigFormat 配置文件格式的编解码器
+type ConfigFormat interface {
+	Unmarshal(data []byte, v any) error
+	Marshal(v any) ([]byte, error)
+}
+
+var (
+	configFormats     = make(map[string]ConfigFormat) // 按扩展名注册的配置文件格式，由 configFormatsLock 保护
+	configFormatsLock sync.RWMutex
+)
+
+func init() {
+	RegisterConfigFormat(YAMLFormat{}, ".yml", ".yaml")
+	RegisterConfigFormat(JSONFormat{}, ".json")
+	RegisterConfigFormat(TOMLFormat{}, ".toml")
+	RegisterConfigFormat(DotenvFormat{}, ".env")
+}
+
+// RegisterConfigFormat 注册配置文件格式，exts 为带点的扩展名，如 ".json"，已存在时覆盖
+func RegisterConfigFormat(format ConfigFormat, exts ...string) {
+	configFormatsLock.Lock()
+	defer configFormatsLock.Unlock()
+
+	for _, ext := range exts {
+		configFormats[strings.ToLower(ext)] = format
+	}
+}
+
+// GetConfigFormat 根据文件扩展名查找配置文件格式
+func GetConfigFormat(path string) (ConfigFormat, bool) {
+	configFormatsLock.RLock()
+	defer configFormatsLock.RUnlock()
+
+	format, ok := configFormats[strings.ToLower(filepath.Ext(path))]
+	return format, ok
+}
+
+// YAMLFormat YAML 格式，使用 yaml 标签
+type YAMLFormat struct{}
+
+func (YAMLFormat) Unmarshal(data []byte, v any) error {
+	return yaml.Unmarshal(data, v)
+}
+
+func (YAMLFormat) Marshal(v any) ([]byte, error) {
+	return yaml.Marshal(v)
+}
+
+// JSONFormat JSON 格式，使用 json 标签，未设置标签时按字段名不区分大小写匹配
+type JSONFormat struct{}
+
+func (JSONFormat) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+func (JSONFormat) Marshal(v any) ([]byte, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// TOMLFormat TOML 格式，使用 toml 标签，未设置标签时按字段名不区分大小写匹配
+type TOMLFormat struct{}
+
+func (TOMLFormat) Unmarshal(data []byte, v any) error {
+	return toml.Unmarshal(data, v)
+}
+
+func (TOMLFormat) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DotenvFormat .env 格式，每行一个 KEY=VALUE。
+// 解码到结构体时，带 env 标签的字段使用标签指定的键，其他字段使用 yaml 路径生成的键，如 db.host 对应 DB_HOST，
+// 值按 YAML 解析，切片可以用逗号分隔；也可以解码到 map[string]string 或 map[string]any。
+type DotenvFormat struct{}
+
+func (DotenvFormat) Unmarshal(data []byte, v any) error {
+	values, err := parseDotenv(data)
+	if err != nil {
+		return err
+	}
+	switch m := v.(type) {
+	case *map[string]string:
+		*m = values
+		return nil
+	case *map[string]any:
+		*m = make(map[string]any, len(values))
+		for k, val := range values {
+			(*m)[k] = val
+		}
+		return nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("dotenv 不支持解码到类型 %T", v)
+	}
+	root := rv.Elem()
+	for _, field := range configFields(root.Type()) {
+		value, ok := values[dotenvKey(field)]
+		if !ok {
+			continue
+		}
+		if err = setConfigField(root, field, value); err != nil {
+			return fmt.Errorf("解析 %s 失败: %v", dotenvKey(field), err)
+		}
+	}
+	return nil
+}
+
+func (DotenvFormat) Marshal(v any) ([]byte, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	var buf bytes.Buffer
+	switch rv.Kind() {
+	case reflect.Map:
+		lines := make(map[string]string, rv.Len())
+		keys := make([]string, 0, rv.Len())
+		for iter := rv.MapRange(); iter.Next(); {
+			k := fmt.Sprint(iter.Key().Interface())
+			lines[k] = quoteDotenv(fmt.Sprint(iter.Value().Interface()))
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&buf, "%s=%s\n", k, lines[k])
+		}
+		return buf.Bytes(), nil
+	case reflect.Struct:
+	default:
+		return nil, fmt.Errorf("dotenv 不支持类型 %T", v)
+	}
+
+	for _, field := range configFields(rv.Type()) {
+		fv, ok := configFieldValue(rv, field)
+		if !ok {
+			continue
+		}
+		value, err := formatConfigValue(fv)
+		if err != nil {
+			return nil, fmt.Errorf("序列化 %s 失败: %v", field.path, err)
+		}
+		fmt.Fprintf(&buf, "%s=%s\n", dotenvKey(field), quoteDotenv(value))
+	}
+	return buf.Bytes(), nil
+}
+
+// dotenvKey 返回字段在 .env 文件中的键
+func dotenvKey(field configField) string {
+	if field.env != "" && field.env != "-" {
+		return field.env
+	}
+	return envName("", field.path)
+}
+
+// parseDotenv 解析 .env 内容，支持 export 前缀、# 注释、单引号和双引号
+func parseDotenv(data []byte) (map[string]string, error) {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("第 %d 行格式错误: %s", lineNo, line)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch {
+		case strings.HasPrefix(value, `"`):
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("第 %d 行引号不匹配: %s", lineNo, line)
+			}
+			value = unquoted
+		case strings.HasPrefix(value, "'"):
+			if len(value) < 2 || !strings.HasSuffix(value, "'") {
+				return nil, fmt.Errorf("第 %d 行引号不匹配: %s", lineNo, line)
+			}
+			value = value[1 : len(value)-1]
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取 dotenv 失败: %v", err)
+	}
+	return values, nil
+}
+
+// quoteDotenv 值包含空白、引号或 # 时加双引号
+func quoteDotenv(value string) string {
+	if strings.ContainsAny(value, " \t\r\n\"'#\\") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// configFieldValue 返回字段的值，沿途有空指针时返回 false
+func configFieldValue(root reflect.Value, field configField) (reflect.Value, bool) {
+	v := root
+	for _, i := range field.index {
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		return reflect.Value{}, false
+	}
+	return v, true
+}
+
+// formatConfigValue 将字段的值格式化为字符串，是 setConfigField 的逆操作
+func formatConfigValue(v reflect.Value) (string, error) {
+	if v.Kind() == reflect.String {
+		return v.String(), nil
+	}
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+		items := make([]string, v.Len())
+		for i := range items {
+			item, err := formatConfigValue(v.Index(i))
+			if err != nil {
+				return "", err
+			}
+			items[i] = item
+		}
+		return strings.Join(items, ","), nil
+	}
+	data, err := yaml.Marshal(v.Interface())
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,411 @@
+package utils
+
+import (
+	"encoding"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// SourceKind 配置项的来源
+type SourceKind string
+
+const (
+	SourceDefault SourceKind = "default" // 默认配置
+	SourceFile    SourceKind = "file"    // 配置文件
+	SourceEnv     SourceKind = "env"     // 环境变量
+	SourceFlag    SourceKind = "flag"    // 命令行参数
+)
+
+// FieldSource 配置项的来源，Name 为配置文件路径、环境变量名或命令行参数名
+type FieldSource struct {
+	Kind SourceKind `json:"kind"`
+	Name string     `json:"name,omitempty"`
+}
+
+func (s FieldSource) String() string {
+	if s.Name == "" {
+		return string(s.Kind)
+	}
+	return string(s.Kind) + ":" + s.Name
+}
+
+// UseEnv 加载配置文件后使用环境变量覆盖配置项。带 env 标签的字段读取标签指定的环境变量，如 `env:"DB_HOST"`；
+// prefix 不为空时，其他字段读取由 prefix 和 yaml 路径生成的环境变量，如 prefix 为 APP 时 db.host 对应 APP_DB_HOST。
+// 环境变量和命令行参数的值按 YAML 解析，切片可以用逗号分隔。
+func (l *ConfigLoader[T]) UseEnv(prefix string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.envEnabled = true
+	l.envPrefix = prefix
+}
+
+// BindFlags 在 fs 上为每个配置项注册命令行参数，须在 fs.Parse 之前调用，加载配置时命令行参数的优先级最高。
+// 参数名为 flag 标签的值或 yaml 路径，如 -db.host，标签为 "-" 时不注册。
+func (l *ConfigLoader[T]) BindFlags(fs *flag.FlagSet) {
+	var cfg T
+	for _, field := range configFields(reflect.TypeOf(cfg)) {
+		if field.flag == "-" {
+			continue
+		}
+		name := field.flag
+		if name == "" {
+			name = field.path
+		}
+		fs.Var(&configFlag{
+			set: func(value string) {
+				l.mutex.Lock()
+				defer l.mutex.Unlock()
+				l.flagValues[field.path] = flagValue{name: name, value: value}
+			},
+			isBool: field.typ.Kind() == reflect.Bool,
+		}, name, "覆盖配置项 "+field.path)
+	}
+}
+
+// Sources 返回每个配置项（以 yaml 路径表示，如 db.host）的来源
+func (l *ConfigLoader[T]) Sources() map[string]FieldSource {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	sources := make(map[string]FieldSource, len(l.sources))
+	for k, v := range l.sources {
+		sources[k] = v
+	}
+	return sources
+}
+
+type flagValue struct {
+	name  string
+	value string
+}
+
+// configFlag 记录命令行参数的值，在加载配置时应用
+type configFlag struct {
+	set    func(value string)
+	isBool bool
+}
+
+func (f *configFlag) String() string {
+	return ""
+}
+
+func (f *configFlag) Set(value string) error {
+	f.set(value)
+	return nil
+}
+
+func (f *configFlag) IsBoolFlag() bool {
+	return f.isBool
+}
+
+// overlaySettings 环境变量和命令行参数的设置快照
+type overlaySettings struct {
+	envEnabled bool
+	envPrefix  string
+	flagValues map[string]flagValue
+}
+
+// overlaySettings 须在持有锁时调用
+func (l *ConfigLoader[T]) overlaySettings() overlaySettings {
+	s := overlaySettings{
+		envEnabled: l.envEnabled,
+		envPrefix:  l.envPrefix,
+		flagValues: make(map[string]flagValue, len(l.flagValues)),
+	}
+	for k, v := range l.flagValues {
+		s.flagValues[k] = v
+	}
+	return s
+}
+
+// overlay 依次以环境变量和命令行参数覆盖 config，返回每个配置项的来源，data 为配置文件 path 的内容，format 为其格式
+func overlay[T any](config *T, path string, data []byte, format ConfigFormat, settings overlaySettings) (map[string]FieldSource, error) {
+	root := reflect.ValueOf(config).Elem()
+	fields := configFields(root.Type())
+	inFile := fileFields(fields, data, format)
+	sources := make(map[string]FieldSource)
+	for _, field := range fields {
+		sources[field.path] = FieldSource{Kind: SourceDefault}
+		if inFile[field.path] {
+			sources[field.path] = FieldSource{Kind: SourceFile, Name: path}
+		}
+
+		if settings.envEnabled {
+			name := field.env
+			if name == "" && settings.envPrefix != "" {
+				name = envName(settings.envPrefix, field.path)
+			}
+			if value, ok := lookupEnv(name); ok {
+				if err := setConfigField(root, field, value); err != nil {
+					return nil, fmt.Errorf("环境变量 %s 的值无效: %v", name, err)
+				}
+				sources[field.path] = FieldSource{Kind: SourceEnv, Name: name}
+			}
+		}
+
+		if fv, ok := settings.flagValues[field.path]; ok {
+			if err := setConfigField(root, field, fv.value); err != nil {
+				return nil, fmt.Errorf("命令行参数 -%s 的值无效: %v", fv.name, err)
+			}
+			sources[field.path] = FieldSource{Kind: SourceFlag, Name: fv.name}
+		}
+	}
+	return sources, nil
+}
+
+// withoutOverrides 将 config 中来源为环境变量或命令行参数的配置项还原为 base 中的值，
+// 沿途的指针会被复制，不修改 config 原有的引用
+func withoutOverrides[T any](config, base T, sources map[string]FieldSource) T {
+	dst := reflect.ValueOf(&config).Elem()
+	src := reflect.ValueOf(&base).Elem()
+	for _, field := range configFields(dst.Type()) {
+		if kind := sources[field.path].Kind; kind != SourceEnv && kind != SourceFlag {
+			continue
+		}
+		value, ok := configFieldValue(src, field)
+		v := dst
+		for _, i := range field.index {
+			if v.Kind() == reflect.Pointer {
+				cp := reflect.New(v.Type().Elem())
+				if !v.IsNil() {
+					cp.Elem().Set(v.Elem())
+				}
+				v.Set(cp)
+				v = cp.Elem()
+			}
+			v = v.Field(i)
+		}
+		if !ok {
+			value = reflect.Zero(v.Type())
+		}
+		v.Set(value)
+	}
+	return config
+}
+
+// configField 配置结构体中可被覆盖的字段
+type configField struct {
+	path  string     // yaml 路径，如 db.host
+	keys  [][]string // 路径每一段在各格式中可能的键名，用于判断配置文件中是否设置了该字段
+	index []int      // 从根结构体开始的字段下标
+	env   string     // env 标签
+	flag  string     // flag 标签
+	typ   reflect.Type
+}
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	yamlUnmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+	timeType            = reflect.TypeOf(time.Time{})
+)
+
+// configFields 返回结构体中的配置项，嵌套结构体展开为多个配置项
+func configFields(t reflect.Type) []configField {
+	var fields []configField
+	collectConfigFields(t, "", nil, nil, map[reflect.Type]bool{}, &fields)
+	return fields
+}
+
+func collectConfigFields(t reflect.Type, prefix string, keys [][]string, index []int, visiting map[reflect.Type]bool, fields *[]configField) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || visiting[t] {
+		return
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+		fieldIndex := append(append([]int(nil), index...), i)
+		fieldKeys := append(append([][]string(nil), keys...), fieldKeyNames(f, name))
+		if strings.Contains(opts, "inline") {
+			path, fieldKeys = prefix, keys
+		}
+
+		if !isConfigLeaf(f.Type) {
+			collectConfigFields(f.Type, path, fieldKeys, fieldIndex, visiting, fields)
+			continue
+		}
+		*fields = append(*fields, configField{
+			path:  path,
+			keys:  fieldKeys,
+			index: fieldIndex,
+			env:   f.Tag.Get("env"),
+			flag:  f.Tag.Get("flag"),
+			typ:   f.Type,
+		})
+	}
+}
+
+// fieldKeyNames 返回字段在配置文件中可能的键名：yaml、json、toml 标签指定的名称和字段名
+func fieldKeyNames(f reflect.StructField, yamlName string) []string {
+	names := []string{yamlName, f.Name}
+	for _, tag := range []string{"json", "toml"} {
+		if name, _, _ := strings.Cut(f.Tag.Get(tag), ","); name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// isConfigLeaf 判断字段是否作为一个整体设置，结构体（time.Time 等可从文本解析的类型除外）会被展开
+func isConfigLeaf(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType {
+		return true
+	}
+	ptr := reflect.PointerTo(t)
+	return ptr.Implements(textUnmarshalerType) || ptr.Implements(yamlUnmarshalerType)
+}
+
+// setConfigField 将字符串值设置到字段，沿途的空指针会被初始化。
+// 字符串字段直接赋值，其他类型按 YAML 解析，切片可以用逗号分隔。
+func setConfigField(root reflect.Value, field configField, value string) error {
+	v := root
+	for _, i := range field.index {
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+
+	switch {
+	case v.Kind() == reflect.String:
+		v.SetString(value)
+		return nil
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 && !strings.HasPrefix(strings.TrimSpace(value), "["):
+		items := strings.Split(value, ",")
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := decodeConfigValue(slice.Index(i), strings.TrimSpace(item)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+	return decodeConfigValue(v, value)
+}
+
+func decodeConfigValue(v reflect.Value, value string) error {
+	if v.Kind() == reflect.String {
+		v.SetString(value)
+		return nil
+	}
+	target := reflect.New(v.Type())
+	if err := yaml.Unmarshal([]byte(value), target.Interface()); err != nil {
+		return err
+	}
+	v.Set(target.Elem())
+	return nil
+}
+
+func lookupEnv(name string) (string, bool) {
+	if name == "" || name == "-" {
+		return "", false
+	}
+	return os.LookupEnv(name)
+}
+
+// envName 由前缀和 yaml 路径生成环境变量名，非字母数字的字符替换为下划线，prefix 为空时不加前缀
+func envName(prefix, path string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, path)
+	if prefix != "" {
+		name = prefix + "_" + name
+	}
+	return strings.ToUpper(name)
+}
+
+// fileFields 返回配置文件中设置了的配置项，键为 yaml 路径
+func fileFields(fields []configField, data []byte, format ConfigFormat) map[string]bool {
+	set := make(map[string]bool)
+	if format == nil {
+		return set
+	}
+	if _, ok := format.(DotenvFormat); ok {
+		var values map[string]string
+		_ = format.Unmarshal(data, &values)
+		for _, field := range fields {
+			if _, ok = values[dotenvKey(field)]; ok {
+				set[field.path] = true
+			}
+		}
+		return set
+	}
+
+	var present map[string]any
+	if format.Unmarshal(data, &present) != nil {
+		return set
+	}
+	for _, field := range fields {
+		if hasConfigKeys(present, field.keys) {
+			set[field.path] = true
+		}
+	}
+	return set
+}
+
+// hasConfigKeys 判断解析后的配置文件中是否存在 keys 对应的配置项，键名不区分大小写
+func hasConfigKeys(node map[string]any, keys [][]string) bool {
+	if node == nil || len(keys) == 0 {
+		return false
+	}
+	var current any = node
+	for _, names := range keys {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return false
+		}
+		if current, ok = lookupConfigKey(m, names); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func lookupConfigKey(m map[string]any, names []string) (any, bool) {
+	for _, name := range names {
+		if v, ok := m[name]; ok {
+			return v, true
+		}
+	}
+	for k, v := range m {
+		for _, name := range names {
+			if strings.EqualFold(k, name) {
+				return v, true
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,369 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+type testConfig struct {
+	Name  string            `yaml:"name"`
+	Port  int               `yaml:"port"`
+	Tags  map[string]string `yaml:"tags"`
+	Debug bool              `yaml:"debug"`
+}
+
+func (c testConfig) Validate() error {
+	if c.Port <= 0 {
+		return errors.New("port 必须大于0")
+	}
+	return nil
+}
+
+func TestConfigLoaderWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.yaml")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("name: a\nport: 8080\ntags:\n  env: dev\n")
+
+	defaults := testConfig{Port: 80, Debug: true, Tags: map[string]string{"region": "cn"}}
+	loader := NewConfigLoader(defaults)
+	if _, err := loader.LoadFromPaths(path); err != nil {
+		t.Fatal(err)
+	}
+	if len(defaults.Tags) != 1 {
+		t.Errorf("加载配置文件不应修改默认配置: %+v", defaults.Tags)
+	}
+	if cfg := loader.GetConfig(); cfg.Tags["region"] != "cn" || cfg.Tags["env"] != "dev" {
+		t.Errorf("加载配置文件应以默认配置为基础: %+v", cfg)
+	}
+	type change struct{ old, new testConfig }
+	changes := make(chan change, 10)
+	loader.OnReload(func(old, new testConfig) {
+		changes <- change{old, new}
+	})
+
+	clock := NewFakeClock(time.Now())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- loader.Watch(ctx, WithWatchClock(clock), WithPollInterval(10*time.Millisecond), WithDebounce(30*time.Millisecond))
+	}()
+	// 等待 Watch 记录文件的初始状态并创建 Ticker
+	for {
+		clock.mutex.Lock()
+		n := len(clock.tickers)
+		clock.mutex.Unlock()
+		if n > 0 {
+			break
+		}
+		runtime.Gosched()
+	}
+
+	write("name: b\nport: 9090\n")
+	clock.Advance(10 * time.Millisecond)
+	select {
+	case c := <-changes:
+		t.Fatalf("debounce 时间内不应重新加载: %+v", c)
+	default:
+	}
+	clock.Advance(30 * time.Millisecond)
+	select {
+	case c := <-changes:
+		if c.old.Name != "a" || c.new.Name != "b" || c.new.Port != 9090 {
+			t.Errorf("变更前后的配置错误: %+v", c)
+		}
+		if !c.new.Debug || c.new.Tags["region"] != "cn" || c.new.Tags["env"] != "" {
+			t.Errorf("重新加载应以默认配置为基础: %+v", c.new)
+		}
+	default:
+		t.Fatal("修改配置文件后应重新加载")
+	}
+	if cfg := loader.GetConfig(); cfg.Name != "b" {
+		t.Errorf("当前配置未更新: %+v", cfg)
+	}
+
+	write("name: c\nport: 0\n")
+	clock.Advance(10 * time.Millisecond)
+	write("name: [broken\n")
+	clock.Advance(100 * time.Millisecond)
+	select {
+	case c := <-changes:
+		t.Errorf("无效的配置不应替换当前配置: %+v", c)
+	default:
+	}
+	if cfg := loader.GetConfig(); cfg.Name != "b" {
+		t.Errorf("无效的配置不应替换当前配置: %+v", cfg)
+	}
+	if err := loader.Reload(); err == nil {
+		t.Error("解析失败时 Reload 应返回错误")
+	}
+	write("name: c\nport: 0\n")
+	if err := loader.Reload(); err == nil {
+		t.Error("校验失败时 Reload 应返回错误")
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("ctx 结束后 Watch 应返回: %v", err)
+	}
+}
+
+type defaultsConfig struct {
+	Name    string         `yaml:"name"`
+	Limits  map[string]int `yaml:"limits"`
+	Retries int            `yaml:"-"`
+	Hook    func() string  `yaml:"-"`
+	secret  string
+}
+
+func TestConfigLoaderDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.yaml")
+	if err := os.WriteFile(path, []byte("name: a\nlimits:\n  x: 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defaults := defaultsConfig{
+		Name:    "default",
+		Limits:  map[string]int{"y": 2},
+		Retries: 3,
+		Hook:    func() string { return "hook" },
+		secret:  "s",
+	}
+	loader := NewConfigLoader(defaults)
+	check := func(stage string, cfg defaultsConfig) {
+		t.Helper()
+		if cfg.Name != "a" || cfg.Limits["x"] != 1 || cfg.Limits["y"] != 2 {
+			t.Errorf("%s: 配置文件应覆盖在默认配置之上: %+v", stage, cfg)
+		}
+		if cfg.Retries != 3 || cfg.secret != "s" || cfg.Hook == nil || cfg.Hook() != "hook" {
+			t.Errorf("%s: 不参与序列化的字段应保留默认值: %+v", stage, cfg)
+		}
+	}
+
+	cfg, err := loader.LoadFromPaths(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("加载", cfg)
+	cfg.Limits["z"] = 3
+	if err = loader.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	cfg = loader.GetConfig()
+	check("重新加载", cfg)
+	if _, ok := cfg.Limits["z"]; ok || len(defaults.Limits) != 1 {
+		t.Errorf("重新加载的配置不应与之前的配置或默认配置共享 map: %v %v", cfg.Limits, defaults.Limits)
+	}
+}
+
+type overlayConfig struct {
+	Name string `yaml:"name"`
+	DB   struct {
+		Host string `yaml:"host" env:"DB_HOST"`
+		Port int    `yaml:"port"`
+	} `yaml:"db"`
+	Timeout time.Duration `yaml:"timeout"`
+	Tags    []string      `yaml:"tags"`
+	Debug   bool          `yaml:"debug" flag:"debug"`
+	Secret  string        `yaml:"secret" env:"-" flag:"-"`
+}
+
+func TestConfigLoaderOverlay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.yaml")
+	if err := os.WriteFile(path, []byte("name: file\ndb:\n  host: file-host\n  port: 3306\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DB_HOST", "env-host")
+	t.Setenv("APP_DB_PORT", "5432")
+	t.Setenv("APP_TIMEOUT", "3s")
+	t.Setenv("APP_TAGS", "a, b")
+	t.Setenv("APP_SECRET", "leaked")
+
+	defaults := overlayConfig{Name: "default", Secret: "default"}
+	loader := NewConfigLoader(defaults)
+	loader.UseEnv("APP")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	loader.BindFlags(fs)
+	if err := fs.Parse([]string{"-db.port=6000", "-debug"}); err != nil {
+		t.Fatal(err)
+	}
+	if fs.Lookup("secret") != nil {
+		t.Error("flag 标签为 - 的字段不应注册命令行参数")
+	}
+
+	cfg, err := loader.LoadFromPaths(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "file" || cfg.DB.Host != "env-host" || cfg.DB.Port != 6000 || cfg.Timeout != 3*time.Second ||
+		!reflect.DeepEqual(cfg.Tags, []string{"a", "b"}) || !cfg.Debug || cfg.Secret != "default" {
+		t.Errorf("配置覆盖顺序错误: %+v", cfg)
+	}
+
+	want := map[string]FieldSource{
+		"name":    {Kind: SourceFile, Name: path},
+		"db.host": {Kind: SourceEnv, Name: "DB_HOST"},
+		"db.port": {Kind: SourceFlag, Name: "db.port"},
+		"timeout": {Kind: SourceEnv, Name: "APP_TIMEOUT"},
+		"tags":    {Kind: SourceEnv, Name: "APP_TAGS"},
+		"debug":   {Kind: SourceFlag, Name: "debug"},
+		"secret":  {Kind: SourceDefault},
+	}
+	if sources := loader.Sources(); !reflect.DeepEqual(sources, want) {
+		t.Errorf("配置来源错误: %v", sources)
+	}
+
+	t.Setenv("APP_TIMEOUT", "not-a-duration")
+	if err = loader.Reload(); err == nil {
+		t.Error("环境变量的值无效时应返回错误")
+	}
+}
+
+type formatConfig struct {
+	Name string `yaml:"name" json:"name" toml:"name"`
+	DB   struct {
+		Host string `yaml:"host" json:"host" toml:"host"`
+		Port int    `yaml:"port" json:"port" toml:"port"`
+	} `yaml:"db" json:"db" toml:"db"`
+	Tags  []string `yaml:"tags" json:"tags" toml:"tags"`
+	Debug bool     `yaml:"debug" json:"debug" toml:"debug"`
+}
+
+func TestConfigLoaderFormats(t *testing.T) {
+	tests := map[string]string{
+		"app.yaml": "name: svc\ndb:\n  host: db.local\n  port: 3306\ntags: [a, b]\n",
+		"app.json": `{"name": "svc", "db": {"host": "db.local", "port": 3306}, "tags": ["a", "b"]}`,
+		"app.toml": "name = \"svc\"\ntags = [\"a\", \"b\"]\n\n[db]\nhost = \"db.local\"\nport = 3306\n",
+		"app.env":  "# legacy\nexport NAME=svc\nDB_HOST=\"db.local\"\nDB_PORT=3306 # mysql\nTAGS='a,b'\n",
+	}
+	for file, content := range tests {
+		t.Run(file, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), file)
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			loader := NewConfigLoader(formatConfig{Debug: true})
+			cfg, err := loader.LoadFromPaths(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Name != "svc" || cfg.DB.Host != "db.local" || cfg.DB.Port != 3306 ||
+				!reflect.DeepEqual(cfg.Tags, []string{"a", "b"}) || !cfg.Debug {
+				t.Errorf("解析配置文件错误: %+v", cfg)
+			}
+			sources := loader.Sources()
+			if sources["db.port"].Kind != SourceFile || sources["debug"].Kind != SourceDefault {
+				t.Errorf("配置来源错误: %v", sources)
+			}
+
+			if err = loader.UpdateConfig(func(c formatConfig) formatConfig {
+				c.Name = "new name"
+				c.Debug = false
+				return c
+			}); err != nil {
+				t.Fatal(err)
+			}
+			saved, err := NewConfigLoader(formatConfig{}).LoadFromPaths(path)
+			if err != nil {
+				t.Fatalf("应能重新加载保存的配置文件: %v", err)
+			}
+			if want := loader.GetConfig(); !reflect.DeepEqual(saved, want) {
+				t.Errorf("保存的配置文件格式错误: %+v，期望 %+v", saved, want)
+			}
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "app.ini")
+	if err := os.WriteFile(path, []byte("name=svc\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewConfigLoader(formatConfig{}).LoadFromPaths(path); err == nil {
+		t.Error("不支持的文件类型应返回错误")
+	}
+}
+
+func TestDotenvFormatMap(t *testing.T) {
+	tests := []struct {
+		v    any
+		want string
+	}{
+		{map[string]string{"B": "2", "A": "a b"}, "A=\"a b\"\nB=2\n"},
+		{map[int]string{10: "x", 2: "y"}, "10=x\n2=y\n"},
+		{&map[string]any{"PORT": 8080}, "PORT=8080\n"},
+	}
+	for _, tt := range tests {
+		data, err := DotenvFormat{}.Marshal(tt.v)
+		if err != nil {
+			t.Fatalf("序列化 %T 失败: %v", tt.v, err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("序列化 %T 结果为 %q，期望 %q", tt.v, data, tt.want)
+		}
+	}
+
+	var values map[string]string
+	if err := (DotenvFormat{}).Unmarshal([]byte(tests[0].want), &values); err != nil || values["A"] != "a b" || values["B"] != "2" {
+		t.Errorf("解析序列化的内容错误: %v %v", values, err)
+	}
+}
+
+func TestConfigLoaderSaveWithoutOverrides(t *testing.T) {
+	type secretConfig struct {
+		Name string `yaml:"name"`
+		DB   struct {
+			User     string `yaml:"user"`
+			Password string `yaml:"password" env:"DB_PASSWORD"`
+		} `yaml:"db"`
+		Port int `yaml:"port"`
+	}
+	path := filepath.Join(t.TempDir(), "app.yaml")
+	if err := os.WriteFile(path, []byte("name: file\ndb:\n  user: root\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DB_PASSWORD", "s3cret")
+
+	loader := NewConfigLoader(secretConfig{Port: 80})
+	loader.UseEnv("")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	loader.BindFlags(fs)
+	if err := fs.Parse([]string{"-port=9090"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loader.LoadFromPaths(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := loader.UpdateConfig(func(c secretConfig) secretConfig {
+		if c.DB.Password != "" || c.Port != 80 {
+			t.Errorf("updater 应只收到默认配置和配置文件中的配置: %+v", c)
+		}
+		c.Name = "updated"
+		return c
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := loader.SaveConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := NewConfigLoader(secretConfig{}).LoadFromPaths(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Name != "updated" || saved.DB.User != "root" || saved.DB.Password != "" || saved.Port != 80 {
+		t.Errorf("环境变量和命令行参数的值不应写入配置文件: %+v", saved)
+	}
+	if cfg := loader.GetConfig(); cfg.Name != "updated" || cfg.DB.Password != "s3cret" || cfg.Port != 9090 {
+		t.Errorf("当前配置应保留环境变量和命令行参数的覆盖: %+v", cfg)
+	}
+}
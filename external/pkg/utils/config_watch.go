@@ -0,0 +1,268 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+)
+
+// WatchOption 监听配置文件的选项
+type WatchOption func(*watchConfig)
+
+type watchConfig struct {
+	pollInterval time.Duration
+	debounce     time.Duration
+	clock        Clock
+}
+
+// WithPollInterval 设置检查配置文件变化的间隔，默认1秒
+func WithPollInterval(interval time.Duration) WatchOption {
+	return func(c *watchConfig) {
+		c.pollInterval = interval
+	}
+}
+
+// WithDebounce 配置文件在该时间内没有再次变化才重新加载，避免读到写入一半的文件，默认500毫秒
+func WithDebounce(debounce time.Duration) WatchOption {
+	return func(c *watchConfig) {
+		c.debounce = debounce
+	}
+}
+
+// WithWatchClock 设置轮询使用的时钟，默认系统时钟，测试中可使用 FakeClock
+func WithWatchClock(clock Clock) WatchOption {
+	return func(c *watchConfig) {
+		c.clock = clock
+	}
+}
+
+// SetValidator 设置重新加载时的配置校验，未设置时若 T 实现了 Validate() error 则使用该方法
+func (l *ConfigLoader[T]) SetValidator(validator func(T) error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.validator = validator
+}
+
+// OnReload 添加重新加载配置后的回调，参数为变更前后的配置
+func (l *ConfigLoader[T]) OnReload(fn func(old, new T)) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.reloadWatchers = append(l.reloadWatchers, fn)
+}
+
+// Watch 轮询配置文件，文件变化且在 debounce 时间内不再变化后调用 Reload，阻塞直到 ctx 结束。
+// 须先通过 LoadFromPaths 加载配置文件，重新加载失败时记录日志并保留当前配置。
+func (l *ConfigLoader[T]) Watch(ctx context.Context, opts ...WatchOption) error {
+	cfg := &watchConfig{pollInterval: time.Second, debounce: 500 * time.Millisecond, clock: SystemClock()}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	path := l.path()
+	if path == "" {
+		return errors.New("未设置配置文件路径")
+	}
+
+	last, _ := statConfigFile(path)
+	var changedAt time.Time
+	pending := false
+	poll := func(now time.Time) {
+		state, err := statConfigFile(path)
+		if err != nil {
+			// 编辑器以重命名方式保存时文件可能短暂不存在
+			GetLogger().Debugf("检查配置文件 %s 失败: %v", path, err)
+			return
+		}
+		if state != last {
+			last, changedAt, pending = state, now, true
+			return
+		}
+		if pending && now.Sub(changedAt) >= cfg.debounce {
+			pending = false
+			if err = l.Reload(); err != nil {
+				GetLogger().Errorf("重新加载配置文件 %s 失败，保留当前配置: %v", path, err)
+			}
+		}
+	}
+
+	ticker := cfg.clock.NewTicker(cfg.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticker.C():
+			poll(now)
+			if st, ok := ticker.(syncTicker); ok {
+				st.ack()
+			}
+		}
+	}
+}
+
+// Reload 重新读取配置文件，以默认配置为基础解析为新的配置并应用环境变量和命令行参数，校验通过后替换当前配置并通知监听器，
+// 失败时保留当前配置
+func (l *ConfigLoader[T]) Reload() error {
+	path := l.path()
+	if path == "" {
+		return errors.New("未设置配置文件路径")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取配置文件失败: %v", err)
+	}
+	config, err := l.parse(path, data)
+	if err != nil {
+		return err
+	}
+	l.mutex.RLock()
+	settings := l.overlaySettings()
+	l.mutex.RUnlock()
+	format, _ := GetConfigFormat(path)
+	sources, err := overlay(&config, path, data, format, settings)
+	if err != nil {
+		return err
+	}
+	if err = l.validate(config); err != nil {
+		return fmt.Errorf("配置校验失败: %v", err)
+	}
+
+	l.mutex.Lock()
+	old := l.config
+	l.config, l.sources = config, sources
+	reloadWatchers := append([]func(old, new T){}, l.reloadWatchers...)
+	l.mutex.Unlock()
+
+	GetLogger().Infof("成功重新加载配置文件: %s", path)
+	l.notifyWatchers()
+	for _, fn := range reloadWatchers {
+		fn(old, config)
+	}
+	return nil
+}
+
+// parse 以默认配置为基础解析配置文件，返回新的配置，不与当前配置共享引用类型的字段
+func (l *ConfigLoader[T]) parse(path string, data []byte) (T, error) {
+	format, ok := GetConfigFormat(path)
+	if !ok {
+		var config T
+		return config, fmt.Errorf("不支持的文件类型: %s", filepath.Ext(path))
+	}
+	config := l.copyDefaults()
+	if err := format.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("解析配置文件失败: %v", err)
+	}
+	return config, nil
+}
+
+// copyDefaults 返回默认配置的深拷贝
+func (l *ConfigLoader[T]) copyDefaults() T {
+	return deepCopy(l.defaults)
+}
+
+// deepCopy 深拷贝 v，复制指针、切片、map 和接口引用的值，保留指针间的共享关系。
+// 无法通过反射设置的未导出字段按值复制，其中的引用类型与 v 共享。
+func deepCopy[T any](v T) T {
+	var out T
+	src := reflect.ValueOf(&v).Elem()
+	reflect.ValueOf(&out).Elem().Set(copyValue(src, make(map[uintptr]reflect.Value)))
+	return out
+}
+
+func copyValue(v reflect.Value, seen map[uintptr]reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		if p, ok := seen[v.Pointer()]; ok {
+			return p
+		}
+		p := reflect.New(v.Type().Elem())
+		seen[v.Pointer()] = p
+		p.Elem().Set(copyValue(v.Elem(), seen))
+		return p
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.Set(copyValue(v.Elem(), seen))
+		return out
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(copyValue(v.Index(i), seen))
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			out.SetMapIndex(copyValue(iter.Key(), seen), copyValue(iter.Value(), seen))
+		}
+		return out
+	case reflect.Array:
+		out := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(copyValue(v.Index(i), seen))
+		}
+		return out
+	case reflect.Struct:
+		out := reflect.New(v.Type()).Elem()
+		out.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if out.Field(i).CanSet() {
+				out.Field(i).Set(copyValue(v.Field(i), seen))
+			}
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func (l *ConfigLoader[T]) validate(config T) error {
+	l.mutex.RLock()
+	validator := l.validator
+	l.mutex.RUnlock()
+
+	if validator != nil {
+		return validator(config)
+	}
+	if v, ok := any(config).(interface{ Validate() error }); ok {
+		return v.Validate()
+	}
+	if v, ok := any(&config).(interface{ Validate() error }); ok {
+		return v.Validate()
+	}
+	return nil
+}
+
+func (l *ConfigLoader[T]) path() string {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return l.configPath
+}
+
+// configFileState 用于判断配置文件是否变化
+type configFileState struct {
+	modTime time.Time
+	size    int64
+}
+
+func statConfigFile(path string) (configFileState, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return configFileState{}, err
+	}
+	return configFileState{modTime: info.ModTime(), size: info.Size()}, nil
+}
@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var delayQueueSeq atomic.Uint64
+
+// DelayQueue 基于时间轮的延迟队列，生产者按延迟放入元素，到期后由消费者按自己的节奏拉取。
+// 到期精度取决于时间轮的 tick 间隔，多个队列可共享同一个时间轮。
+type DelayQueue[T any] struct {
+	tw     *TimingWheel
+	prefix string // 时间轮任务ID前缀，避免与其他任务冲突
+	seq    atomic.Uint64
+
+	mutex   sync.Mutex
+	pending map[string]*delayEntry[T] // 未到期的元素
+	ready   *list.List                // 已到期的元素，按到期顺序排列
+	readyAt map[string]*list.Element
+	notify  chan struct{}
+}
+
+type delayEntry[T any] struct {
+	key  string
+	item T
+}
+
+// NewDelayQueue 创建延迟队列，tw 需已启动
+func NewDelayQueue[T any](tw *TimingWheel) *DelayQueue[T] {
+	return &DelayQueue[T]{
+		tw:      tw,
+		prefix:  fmt.Sprintf("delay-queue-%d/", delayQueueSeq.Add(1)),
+		pending: make(map[string]*delayEntry[T]),
+		ready:   list.New(),
+		readyAt: make(map[string]*list.Element),
+		notify:  make(chan struct{}, 1),
+	}
+}
+
+// Offer 放入 delay 后到期的元素，返回生成的键
+func (q *DelayQueue[T]) Offer(item T, delay time.Duration) (string, error) {
+	key := fmt.Sprintf("item-%d", q.seq.Add(1))
+	return key, q.OfferWithKey(key, item, delay)
+}
+
+// OfferWithKey 以指定键放入 delay 后到期的元素，键已存在时替换原元素并重新计时
+func (q *DelayQueue[T]) OfferWithKey(key string, item T, delay time.Duration) error {
+	entry := &delayEntry[T]{key: key, item: item}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	err := q.tw.AddErrTask(entry, func(data any, tc TaskContext) error {
+		q.expire(data.(*delayEntry[T]))
+		return nil
+	}, delay, WithTaskKey(q.prefix+key, KeyReplace))
+	if err != nil {
+		return err
+	}
+
+	q.removeReadyLocked(key)
+	q.pending[key] = entry
+	return nil
+}
+
+// expire 将到期元素移入就绪列表，已被移除或替换的元素直接丢弃
+func (q *DelayQueue[T]) expire(entry *delayEntry[T]) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.pending[entry.key] != entry {
+		return
+	}
+	delete(q.pending, entry.key)
+	q.readyAt[entry.key] = q.ready.PushBack(entry)
+	q.signal()
+}
+
+// signal 唤醒一个等待中的 Take
+func (q *DelayQueue[T]) signal() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Poll 取出一个已到期的元素，没有到期元素时立即返回 false
+func (q *DelayQueue[T]) Poll() (item T, ok bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	front := q.ready.Front()
+	if front == nil {
+		return item, false
+	}
+	entry := q.ready.Remove(front).(*delayEntry[T])
+	delete(q.readyAt, entry.key)
+	if q.ready.Len() > 0 {
+		// 仍有到期元素，继续唤醒其他等待者
+		q.signal()
+	}
+	return entry.item, true
+}
+
+// Take 阻塞直到取出一个已到期的元素或 ctx 结束
+func (q *DelayQueue[T]) Take(ctx context.Context) (T, error) {
+	for {
+		if item, ok := q.Poll(); ok {
+			return item, nil
+		}
+		select {
+		case <-q.notify:
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+	}
+}
+
+// Len 返回队列中的元素总数，包括未到期和已到期未取出的元素
+func (q *DelayQueue[T]) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return len(q.pending) + q.ready.Len()
+}
+
+// Remove 按键移除元素，元素不存在时返回 false
+func (q *DelayQueue[T]) Remove(key string) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if _, ok := q.pending[key]; ok {
+		delete(q.pending, key)
+		// 时间轮中的任务可能已在触发途中，expire 会因元素已移除而丢弃
+		_ = q.tw.CancelTask(q.prefix + key)
+		return true
+	}
+	return q.removeReadyLocked(key)
+}
+
+func (q *DelayQueue[T]) removeReadyLocked(key string) bool {
+	e, ok := q.readyAt[key]
+	if !ok {
+		return false
+	}
+	q.ready.Remove(e)
+	delete(q.readyAt, key)
+	return true
+}
@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDelayQueue(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local))
+	tw := NewTimingWheel(1, 60, WithClock(clock))
+	if err := tw.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer tw.Stop()
+
+	q := NewDelayQueue[string](tw)
+	for _, item := range []struct {
+		key   string
+		delay time.Duration
+	}{{"c", 3 * time.Second}, {"a", time.Second}, {"b", 2 * time.Second}, {"d", 4 * time.Second}} {
+		if err := q.OfferWithKey(item.key, item.key, item.delay); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if q.Len() != 4 {
+		t.Fatalf("队列长度为 %d，期望 4", q.Len())
+	}
+	if _, ok := q.Poll(); ok {
+		t.Fatal("未到期时不应取出元素")
+	}
+	if !q.Remove("d") || q.Remove("d") {
+		t.Error("移除结果不正确")
+	}
+
+	clock.Advance(3 * time.Second)
+	for _, want := range []string{"a", "b", "c"} {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		got, err := q.Take(ctx)
+		cancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("取出 %s，期望 %s", got, want)
+		}
+	}
+
+	clock.Advance(2 * time.Second)
+	if _, ok := q.Poll(); ok {
+		t.Error("已移除的元素不应到期")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := q.Take(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("空队列 Take 应随 ctx 超时返回，实际 %v", err)
+	}
+	if q.Len() != 0 {
+		t.Errorf("队列长度为 %d，期望 0", q.Len())
+	}
+}
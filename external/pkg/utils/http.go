@@ -3,22 +3,42 @@ package utils
 import (
 	"bytes"
 	"context"
-	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
-	"net"
 	"net/http"
 	"net/url"
+	"sync"
 	"time"
 )
 
+// 默认重试策略的最大间隔
+const defaultRetryMaxDelay = 30 * time.Second
+
 type HttpClientWrapper struct {
-	Domain     string
-	client     *http.Client
-	timeout    time.Duration
-	retryTimes int
-	retryDelay time.Duration
+	Domain         string
+	client         *http.Client
+	transferClient *http.Client // 不设置超时的客户端，用于 Upload、Download
+	timeout        time.Duration
+	transport      http.RoundTripper
+	retryPolicy    RetryPolicy
+
+	breakerConfig *CircuitBreakerConfig
+	breakers      map[string]*CircuitBreaker // 按目标主机区分的熔断器，由 breakersLock 保护
+	breakersLock  sync.Mutex
+
+	middlewares    []Middleware
+	chain          RoundTripFunc // 由 middlewares 组合而成，由 middlewareLock 保护
+	middlewareLock sync.RWMutex
+
+	cache *httpCache   // 未启用缓存时为nil
+	auth  AuthProvider // 未启用认证时为nil
+
+	limiter      *requestLimiter // 客户端整体的限流器，未启用时为nil
+	hostLimit    *RateLimit
+	hostLimits   map[string]RateLimit
+	hostLimiters map[string]*requestLimiter // 按目标主机区分的限流器，由 limitersLock 保护
+	limitersLock sync.Mutex
+	limitWait    *Histogram
 }
 
 type Option func(*HttpClientWrapper)
@@ -29,148 +49,205 @@ func WithTimeout(timeout time.Duration) Option {
 	}
 }
 
+// WithRetry 使用默认重试策略，最多重试 times 次，delay 为首次重试的基础间隔
 func WithRetry(times int, delay time.Duration) Option {
 	return func(w *HttpClientWrapper) {
-		w.retryTimes = times
-		w.retryDelay = delay
+		w.retryPolicy = &DefaultRetryPolicy{
+			MaxAttempts: times + 1,
+			BaseDelay:   delay,
+			MaxDelay:    defaultRetryMaxDelay,
+		}
 	}
 }
 
 func NewHttpClientWrapper(domain string, opts ...Option) *HttpClientWrapper {
 	wrapper := &HttpClientWrapper{
-		Domain:     domain,
-		timeout:    10 * time.Second,
-		retryTimes: 3,
-		retryDelay: time.Second,
+		Domain:       domain,
+		timeout:      10 * time.Second,
+		transport:    defaultTransport,
+		breakers:     make(map[string]*CircuitBreaker),
+		hostLimiters: make(map[string]*requestLimiter),
+		limitWait:    NewHistogram(defaultLatencyBuckets),
+		retryPolicy: &DefaultRetryPolicy{
+			MaxAttempts: 4,
+			BaseDelay:   time.Second,
+			MaxDelay:    defaultRetryMaxDelay,
+		},
 	}
 
 	for _, opt := range opts {
 		opt(wrapper)
 	}
 	wrapper.client = &http.Client{
-		Timeout: wrapper.timeout,
+		Timeout:   wrapper.timeout,
+		Transport: wrapper.transport,
 	}
+	wrapper.transferClient = &http.Client{Transport: wrapper.transport}
 
 	return wrapper
 }
 
-func (w *HttpClientWrapper) doWithRetry(req *http.Request) (*http.Response, error) {
-	var (
-		resp      *http.Response
-		err       error
-		allErrors []error
-	)
-
-	for i := 0; i <= w.retryTimes; i++ {
-		// 每次重试创建新请求体
-		if req.GetBody != nil {
-			if bodyCopy, err := req.GetBody(); err == nil {
-				req.Body = bodyCopy
-			}
-		}
-
-		resp, err = w.client.Do(req)
-		if err == nil {
-			// 检查服务端错误状态码
-			if resp.StatusCode >= 500 {
-				allErrors = append(allErrors, fmt.Errorf("服务端错误 %d", resp.StatusCode))
-				io.Copy(io.Discard, resp.Body)
-				resp.Body.Close()
-				continue
-			}
-			return resp, nil
-		}
-
-		// 记录错误并判断是否重试
-		allErrors = append(allErrors, err)
-		if !isRetriableError(err) {
-			break
-		}
+func (w *HttpClientWrapper) Get(api string, header map[string]string, queryParams url.Values, ctx ...context.Context) (*http.Response, error) {
+	return w.request(http.MethodGet, api, header, queryParams, nil, ctx...)
+}
 
-		// 指数退避
-		delay := time.Duration(1<<uint(i)) * w.retryDelay
-		time.Sleep(delay)
-	}
+func (w *HttpClientWrapper) Post(api string, header map[string]string, queryParams url.Values, body []byte, ctx ...context.Context) (*http.Response, error) {
+	return w.request(http.MethodPost, api, header, queryParams, body, ctx...)
+}
 
-	return nil, fmt.Errorf("请求失败（共尝试 %d 次）: %v", len(allErrors), allErrors)
+// Put 发送 PUT 请求
+func (w *HttpClientWrapper) Put(ctx context.Context, api string, opts ...RequestOption) (*http.Response, error) {
+	return w.Do(ctx, http.MethodPut, api, opts...)
 }
 
-func isRetriableError(err error) bool {
-	var netErr net.Error
-	if errors.As(err, &netErr) {
-		return netErr.Timeout() || netErr.Temporary()
-	}
-	return false
+// Patch 发送 PATCH 请求
+func (w *HttpClientWrapper) Patch(ctx context.Context, api string, opts ...RequestOption) (*http.Response, error) {
+	return w.Do(ctx, http.MethodPatch, api, opts...)
 }
 
-func (w *HttpClientWrapper) Get(api string, header map[string]string, queryParams url.Values, ctx ...context.Context) (*http.Response, error) {
-	return w.request(http.MethodGet, api, header, queryParams, nil, ctx...)
+// Delete 发送 DELETE 请求
+func (w *HttpClientWrapper) Delete(ctx context.Context, api string, opts ...RequestOption) (*http.Response, error) {
+	return w.Do(ctx, http.MethodDelete, api, opts...)
 }
 
-func (w *HttpClientWrapper) Post(api string, header map[string]string, queryParams url.Values, body []byte, ctx ...context.Context) (*http.Response, error) {
-	return w.request(http.MethodPost, api, header, queryParams, body, ctx...)
+// Head 发送 HEAD 请求
+func (w *HttpClientWrapper) Head(ctx context.Context, api string, opts ...RequestOption) (*http.Response, error) {
+	return w.Do(ctx, http.MethodHead, api, opts...)
 }
 
-func (w *HttpClientWrapper) request(method, api string, header map[string]string, queryParams url.Values, body []byte, ctx ...context.Context) (*http.Response, error) {
-	var reader io.Reader
-	if body != nil {
-		reader = bytes.NewReader(body)
+// Do 发送请求，请求头、查询参数、请求体、认证和超时通过 RequestOption 设置。
+// 设置了 WithRequestTimeout 时，超时在关闭响应体后释放，调用方必须关闭响应体。
+func (w *HttpClientWrapper) Do(ctx context.Context, method, api string, opts ...RequestOption) (*http.Response, error) {
+	cfg := newRequestConfig(opts)
+	if cfg.err != nil {
+		return nil, cfg.err
 	}
 
-	apiURL := w.Domain + api
-	if queryParams != nil {
-		apiURL = apiURL + "?" + queryParams.Encode()
+	apiURL, err := w.buildURL(api, cfg.query)
+	if err != nil {
+		return nil, err
 	}
 
-	var req *http.Request
-	var err error
-
-	if len(ctx) > 0 {
-		req, err = http.NewRequestWithContext(ctx[0], method, apiURL, reader)
-	} else {
-		req, err = http.NewRequest(method, apiURL, reader)
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	cancel := context.CancelFunc(func() {})
+	if cfg.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, cfg.timeout)
 	}
 
+	var reader io.Reader
+	if cfg.bodyReader != nil {
+		reader = cfg.bodyReader
+	} else if cfg.hasBody {
+		reader = bytes.NewReader(cfg.body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, apiURL, reader)
 	if err != nil {
+		cancel()
 		return nil, fmt.Errorf("创建请求失败: %v", err)
 	}
+	for k, vs := range cfg.header {
+		req.Header[k] = vs
+	}
+	if cfg.contentType != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", cfg.contentType)
+	}
 
-	// 设置通用header
-	req.Header.Set("Content-Type", "application/json")
-	for k, v := range header {
-		req.Header.Set(k, v)
+	var resp *http.Response
+	if w.cache != nil {
+		resp, err = w.cache.do(req, w.doWithAuth)
+	} else {
+		resp, err = w.doWithAuth(req)
 	}
-	GetLogger().Debugf("发送HTTP请求-method:%s url:%s body:%s", method, apiURL, string(body))
-	return w.doWithRetry(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// request 兼容旧接口：无论是否有请求体，header 中未指定时 Content-Type 为 application/json，并记录请求的调试日志
+func (w *HttpClientWrapper) request(method, api string, header map[string]string, queryParams url.Values, body []byte, ctx ...context.Context) (*http.Response, error) {
+	opts := []RequestOption{WithHeader("Content-Type", "application/json"), WithHeaders(header), WithQuery(queryParams)}
+	if body != nil {
+		opts = append(opts, WithBody(body, ""))
+	}
+	if apiURL, err := w.buildURL(api, queryParams); err == nil {
+		GetLogger().Debugf("发送HTTP请求-method:%s url:%s body:%s", method, apiURL, string(body))
+	}
+
+	c := context.Background()
+	if len(ctx) > 0 {
+		c = ctx[0]
+	}
+	return w.Do(c, method, api, opts...)
 }
 
-func HandleResponse[T any](response *http.Response) (body T, err error) {
+// HandleResponse 读取并关闭响应体，状态码为 2xx 时将响应体解码为 T，响应体为空（如 204）时返回零值；
+// 其他状态码返回 *HTTPError。编解码器由 WithResponseCodec 指定或根据响应的 Content-Type 选择，默认 JSON，
+// T 为 string 或 []byte 且响应为 text/* 或 application/octet-stream 时直接接收原始响应体
+func HandleResponse[T any](response *http.Response, opts ...ResponseOption) (body T, err error) {
 	defer response.Body.Close()
 	bodyBytes, err := io.ReadAll(response.Body)
 	if err != nil {
 		return
 	}
 	GetLogger().Debugf("url:%s,responseStatus:%d,responseBody: %s", response.Request.URL, response.StatusCode, string(bodyBytes))
-	if response.StatusCode != http.StatusOK {
-		err = errors.New(fmt.Sprintf("reponse:%s, status not 200,status:%d,body:%s", response.Request.URL.Path, response.StatusCode, string(bodyBytes)))
+
+	cfg := &responseConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		cfg.decodeErrorBody(response, bodyBytes)
+		err = newHTTPError(response, bodyBytes)
+		return
+	}
+	if len(bodyBytes) == 0 {
 		return
 	}
-	err = json.Unmarshal(bodyBytes, &body)
+	if err = cfg.decode(response, bodyBytes, &body); err != nil {
+		err = fmt.Errorf("解码响应体失败: %v", err)
+	}
 	return
 }
 
+// DoRequest 发送一次请求并解码响应，header 中的 Content-Type 覆盖默认的 application/json，
+// 响应体按 HandleResponse 的规则选择编解码器。相同域名和超时时间的调用复用同一个客户端。
 func DoRequest[ResponseStruct any](method, domain, api string, header map[string]string, queryParams url.Values, body []byte, expiredTime ...time.Duration) (resStruct ResponseStruct, err error) {
-	var wrapper *HttpClientWrapper
-	if len(expiredTime) > 0 {
-		wrapper = NewHttpClientWrapper(domain, WithTimeout(expiredTime[0]))
-	} else {
-		wrapper = NewHttpClientWrapper(domain)
+	resp, err := defaultClient(domain, expiredTime).request(method, api, header, queryParams, body)
+	if err != nil {
+		return
 	}
 
-	resp, err := wrapper.request(method, api, header, queryParams, body)
+	return HandleResponse[ResponseStruct](resp)
+}
+
+// DoEncodedRequest 与 DoRequest 相同，但使用 contentType 对应的编解码器将 body 编码为请求体，body 为 nil 时不发送请求体。
+// opts 指定解码响应体的选项，如 WithResponseCodec。
+func DoEncodedRequest[ResponseStruct any](method, domain, api string, header map[string]string, queryParams url.Values, contentType string, body any, expiredTime time.Duration, opts ...ResponseOption) (resStruct ResponseStruct, err error) {
+	reqOpts := []RequestOption{WithHeaders(header), WithQuery(queryParams)}
+	if body != nil {
+		reqOpts = append(reqOpts, WithEncodedBody(contentType, body))
+	}
+	var expired []time.Duration
+	if expiredTime > 0 {
+		expired = append(expired, expiredTime)
+	}
+	resp, err := defaultClient(domain, expired).Do(context.Background(), method, api, reqOpts...)
 	if err != nil {
 		return
 	}
 
-	return HandleResponse[ResponseStruct](resp)
+	return HandleResponse[ResponseStruct](resp, opts...)
+}
+
+// defaultClient 从默认注册表获取 domain 和超时时间对应的客户端
+func defaultClient(domain string, expiredTime []time.Duration) *HttpClientWrapper {
+	if len(expiredTime) > 0 {
+		return defaultClients.Get(domain, expiredTime[0].String(), WithTimeout(expiredTime[0]))
+	}
+	return defaultClients.Get(domain, "")
 }
@@ -0,0 +1,218 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"golang.org/x/sync/singleflight"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuthProvider 请求认证，启用后客户端在发送请求前调用 Authorize，响应 401 时调用 Refresh 后重试一次
+type AuthProvider interface {
+	// Authorize 为请求设置认证信息
+	Authorize(req *http.Request) error
+	// Refresh 强制刷新 req 使用的凭证，凭证已被其他请求刷新时可直接返回
+	Refresh(req *http.Request) error
+}
+
+// WithAuth 为客户端的请求设置认证
+func WithAuth(provider AuthProvider) Option {
+	return func(w *HttpClientWrapper) {
+		w.auth = provider
+	}
+}
+
+// doWithAuth 设置认证后发送请求，响应 401 且请求体可以重建时刷新凭证并重试一次
+func (w *HttpClientWrapper) doWithAuth(req *http.Request) (*http.Response, error) {
+	if w.auth == nil {
+		return w.doWithRetry(req)
+	}
+	if err := w.auth.Authorize(req); err != nil {
+		return nil, fmt.Errorf("设置认证信息失败: %v", err)
+	}
+	resp, err := w.doWithRetry(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+
+	drainBody(resp)
+	if err = w.auth.Refresh(req); err != nil {
+		return nil, fmt.Errorf("刷新认证信息失败: %v", err)
+	}
+	if req.GetBody != nil {
+		if req.Body, err = req.GetBody(); err != nil {
+			return nil, fmt.Errorf("重建请求体失败: %v", err)
+		}
+	}
+	if err = w.auth.Authorize(req); err != nil {
+		return nil, fmt.Errorf("设置认证信息失败: %v", err)
+	}
+	return w.doWithRetry(req)
+}
+
+// Token OAuth2 访问令牌
+type Token struct {
+	AccessToken string
+	TokenType   string
+	ExpiresAt   time.Time // 零值表示未返回有效期
+}
+
+// authorization 返回 Authorization 请求头的值
+func (t *Token) authorization() string {
+	if t.TokenType == "" || strings.EqualFold(t.TokenType, "bearer") {
+		return "Bearer " + t.AccessToken
+	}
+	return t.TokenType + " " + t.AccessToken
+}
+
+// ClientCredentialsConfig OAuth2 客户端凭证模式配置
+type ClientCredentialsConfig struct {
+	TokenURL       string
+	ClientID       string
+	ClientSecret   string
+	Scopes         []string
+	EndpointParams url.Values         // 获取令牌时的额外参数
+	AuthInParams   bool               // 以表单参数而不是 Basic 认证传递客户端ID和密钥
+	RefreshBefore  time.Duration      // 令牌过期前多久开始在后台刷新，默认1分钟
+	Client         *HttpClientWrapper // 请求令牌使用的客户端，默认使用共享连接池的新客户端
+}
+
+// ClientCredentials OAuth2 客户端凭证模式的 AuthProvider，缓存令牌并在过期前主动刷新，
+// 并发请求同时刷新时只请求一次令牌
+type ClientCredentials struct {
+	config ClientCredentialsConfig
+	clock  Clock
+	group  singleflight.Group
+
+	mutex sync.Mutex
+	token *Token
+}
+
+// NewClientCredentials 创建客户端凭证模式的 AuthProvider
+func NewClientCredentials(config ClientCredentialsConfig) *ClientCredentials {
+	return newClientCredentials(config, SystemClock())
+}
+
+func newClientCredentials(config ClientCredentialsConfig, clock Clock) *ClientCredentials {
+	if config.RefreshBefore <= 0 {
+		config.RefreshBefore = time.Minute
+	}
+	if config.Client == nil {
+		config.Client = NewHttpClientWrapper("")
+	}
+	return &ClientCredentials{config: config, clock: clock}
+}
+
+// Token 返回缓存的令牌，令牌即将过期时在后台刷新并返回当前令牌，已过期或不存在时获取新令牌
+func (c *ClientCredentials) Token(ctx context.Context) (*Token, error) {
+	c.mutex.Lock()
+	token := c.token
+	c.mutex.Unlock()
+
+	if token != nil && !token.ExpiresAt.IsZero() {
+		remaining := token.ExpiresAt.Sub(c.clock.Now())
+		switch {
+		case remaining <= 0:
+			token = nil
+		case remaining <= c.config.RefreshBefore:
+			c.group.DoChan("token", c.fetch)
+		}
+	}
+	if token != nil {
+		return token, nil
+	}
+
+	select {
+	case res := <-c.group.DoChan("token", c.fetch):
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*Token), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Authorize 实现 AuthProvider
+func (c *ClientCredentials) Authorize(req *http.Request) error {
+	token, err := c.Token(req.Context())
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", token.authorization())
+	return nil
+}
+
+// Refresh 实现 AuthProvider，req 使用的仍是当前令牌时丢弃该令牌并获取新令牌
+func (c *ClientCredentials) Refresh(req *http.Request) error {
+	c.mutex.Lock()
+	if c.token != nil && c.token.authorization() == req.Header.Get("Authorization") {
+		c.token = nil
+	}
+	c.mutex.Unlock()
+
+	_, err := c.Token(req.Context())
+	return err
+}
+
+// fetch 请求新令牌并缓存，由 singleflight 保证同一时间只有一个请求，不受单个调用方上下文的影响
+func (c *ClientCredentials) fetch() (any, error) {
+	token, err := c.requestToken()
+	if err != nil {
+		GetLogger().Warnf("%v", err)
+		return nil, err
+	}
+	c.mutex.Lock()
+	c.token = token
+	c.mutex.Unlock()
+	GetLogger().Debugf("已获取令牌，有效期至 %v", token.ExpiresAt)
+	return token, nil
+}
+
+func (c *ClientCredentials) requestToken() (*Token, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.config.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.config.Scopes, " "))
+	}
+	for k, vs := range c.config.EndpointParams {
+		form[k] = vs
+	}
+	opts := []RequestOption{WithHeader("Accept", "application/json")}
+	if c.config.AuthInParams {
+		form.Set("client_id", c.config.ClientID)
+		form.Set("client_secret", c.config.ClientSecret)
+	} else {
+		opts = append(opts, WithBasicAuth(url.QueryEscape(c.config.ClientID), url.QueryEscape(c.config.ClientSecret)))
+	}
+	opts = append(opts, WithFormBody(form))
+
+	requestedAt := c.clock.Now()
+	resp, err := c.config.Client.Do(context.Background(), http.MethodPost, c.config.TokenURL, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("获取令牌失败: %v", err)
+	}
+	res, err := HandleResponse[struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}](resp)
+	if err != nil {
+		return nil, fmt.Errorf("获取令牌失败: %v", err)
+	}
+	if res.AccessToken == "" {
+		return nil, fmt.Errorf("获取令牌失败: 响应中没有 access_token")
+	}
+
+	token := &Token{AccessToken: res.AccessToken, TokenType: res.TokenType}
+	if res.ExpiresIn > 0 {
+		token.ExpiresAt = requestedAt.Add(time.Duration(res.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
@@ -0,0 +1,249 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 熔断器打开，请求未发送直接失败
+var ErrCircuitOpen = errors.New("熔断器已打开，请求被拒绝")
+
+// CircuitState 熔断器状态
+type CircuitState int8
+
+const (
+	CircuitClosed   CircuitState = iota // 关闭，正常放行请求
+	CircuitOpen                         // 打开，拒绝所有请求
+	CircuitHalfOpen                     // 半开，放行少量探测请求
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// CircuitBreakerConfig 熔断器配置，零值字段使用默认值
+type CircuitBreakerConfig struct {
+	FailureRatio   float64       // 统计窗口内失败比例达到该值时打开，默认0.5
+	MinRequests    int           // 统计窗口内请求数达到该值后才计算失败比例，默认10
+	Window         time.Duration // 统计窗口，默认10秒
+	OpenDuration   time.Duration // 打开后经过该时间进入半开，默认30秒
+	HalfOpenProbes int           // 半开时放行的探测请求数，全部成功后关闭，默认1
+}
+
+func (c CircuitBreakerConfig) withDefaults() CircuitBreakerConfig {
+	if c.FailureRatio <= 0 {
+		c.FailureRatio = 0.5
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = 10
+	}
+	if c.Window <= 0 {
+		c.Window = 10 * time.Second
+	}
+	if c.OpenDuration <= 0 {
+		c.OpenDuration = 30 * time.Second
+	}
+	if c.HalfOpenProbes <= 0 {
+		c.HalfOpenProbes = 1
+	}
+	return c
+}
+
+// CircuitBreaker 熔断器，请求失败比例过高时打开，一段时间后通过探测请求恢复
+type CircuitBreaker struct {
+	config CircuitBreakerConfig
+	clock  Clock
+
+	mutex       sync.Mutex
+	state       CircuitState
+	generation  uint64 // 每次状态切换递增，忽略切换前发出的请求结果
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	probes      int // 半开时已放行的探测请求数
+	successes   int // 半开时成功的探测请求数
+}
+
+// NewCircuitBreaker 创建熔断器
+func NewCircuitBreaker(config CircuitBreakerConfig) *CircuitBreaker {
+	return newCircuitBreaker(config, SystemClock())
+}
+
+func newCircuitBreaker(config CircuitBreakerConfig, clock Clock) *CircuitBreaker {
+	return &CircuitBreaker{
+		config:      config.withDefaults(),
+		clock:       clock,
+		windowStart: clock.Now(),
+	}
+}
+
+// State 返回熔断器当前状态
+func (b *CircuitBreaker) State() CircuitState {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.state == CircuitOpen && b.clock.Now().Sub(b.openedAt) >= b.config.OpenDuration {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+// 请求结果
+type breakerOutcome int8
+
+const (
+	outcomeSuccess breakerOutcome = iota
+	outcomeFailure
+	outcomeIgnored // 调用方取消等与下游健康无关的结果，不计入统计
+)
+
+// Allow 判断是否放行请求，放行时返回的 done 必须以请求是否成功调用一次
+func (b *CircuitBreaker) Allow() (done func(success bool), err error) {
+	report, err := b.allow()
+	if err != nil {
+		return nil, err
+	}
+	return func(success bool) {
+		if success {
+			report(outcomeSuccess)
+		} else {
+			report(outcomeFailure)
+		}
+	}, nil
+}
+
+func (b *CircuitBreaker) allow() (func(outcome breakerOutcome), error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := b.clock.Now()
+	switch b.state {
+	case CircuitOpen:
+		if now.Sub(b.openedAt) < b.config.OpenDuration {
+			return nil, ErrCircuitOpen
+		}
+		b.setState(CircuitHalfOpen, now)
+		fallthrough
+	case CircuitHalfOpen:
+		if b.probes >= b.config.HalfOpenProbes {
+			return nil, ErrCircuitOpen
+		}
+		b.probes++
+	default:
+		if now.Sub(b.windowStart) >= b.config.Window {
+			b.windowStart = now
+			b.requests, b.failures = 0, 0
+		}
+	}
+
+	generation := b.generation
+	return func(outcome breakerOutcome) {
+		b.report(generation, outcome)
+	}, nil
+}
+
+func (b *CircuitBreaker) report(generation uint64, outcome breakerOutcome) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+	now := b.clock.Now()
+	switch b.state {
+	case CircuitHalfOpen:
+		switch outcome {
+		case outcomeIgnored:
+			// 释放探测名额
+			b.probes--
+			return
+		case outcomeFailure:
+			b.setState(CircuitOpen, now)
+			return
+		}
+		b.successes++
+		if b.successes >= b.config.HalfOpenProbes {
+			b.setState(CircuitClosed, now)
+		}
+	case CircuitClosed:
+		if outcome == outcomeIgnored {
+			return
+		}
+		b.requests++
+		if outcome == outcomeFailure {
+			b.failures++
+		}
+		if b.requests >= b.config.MinRequests && float64(b.failures)/float64(b.requests) >= b.config.FailureRatio {
+			b.setState(CircuitOpen, now)
+		}
+	}
+}
+
+func (b *CircuitBreaker) setState(state CircuitState, now time.Time) {
+	GetLogger().Warnf("熔断器状态变更: %s -> %s", b.state, state)
+	b.state = state
+	b.generation++
+	b.windowStart = now
+	b.requests, b.failures = 0, 0
+	b.probes, b.successes = 0, 0
+	if state == CircuitOpen {
+		b.openedAt = now
+	}
+}
+
+// WithCircuitBreaker 为每个目标主机启用独立的熔断器
+func WithCircuitBreaker(config CircuitBreakerConfig) Option {
+	return func(w *HttpClientWrapper) {
+		w.breakerConfig = &config
+	}
+}
+
+// breaker 返回目标主机的熔断器，未启用熔断时返回nil
+func (w *HttpClientWrapper) breaker(host string) *CircuitBreaker {
+	if w.breakerConfig == nil {
+		return nil
+	}
+	w.breakersLock.Lock()
+	defer w.breakersLock.Unlock()
+
+	b, ok := w.breakers[host]
+	if !ok {
+		b = NewCircuitBreaker(*w.breakerConfig)
+		w.breakers[host] = b
+	}
+	return b
+}
+
+// CircuitStates 返回各目标主机熔断器的当前状态，用于监控
+func (w *HttpClientWrapper) CircuitStates() map[string]CircuitState {
+	w.breakersLock.Lock()
+	defer w.breakersLock.Unlock()
+
+	states := make(map[string]CircuitState, len(w.breakers))
+	for host, b := range w.breakers {
+		states[host] = b.State()
+	}
+	return states
+}
+
+// breakerResult 判断请求结果：网络错误和 5xx 为失败，调用方取消不计入
+func breakerResult(req *http.Request, resp *http.Response, err error) breakerOutcome {
+	switch {
+	case err != nil && req.Context().Err() != nil:
+		return outcomeIgnored
+	case err != nil || resp.StatusCode >= 500:
+		return outcomeFailure
+	default:
+		return outcomeSuccess
+	}
+}
@@ -0,0 +1,392 @@
+package utils
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 缓存命中情况的响应头
+const cacheStatusHeader = "X-Cache"
+
+const (
+	CacheHit         = "HIT"         // 缓存未过期，未请求上游
+	CacheRevalidated = "REVALIDATED" // 上游返回 304，使用缓存的响应体
+	CacheStale       = "STALE"       // 上游失败，返回过期缓存
+	CacheMiss        = "MISS"        // 未命中缓存，使用上游响应
+)
+
+// CacheEntry 缓存的响应
+type CacheEntry struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	StoredAt   time.Time   // 存储或最近一次重新验证的时间
+	Vary       http.Header // 按 Vary 响应头记录的请求头，请求头不一致时视为未命中
+}
+
+func (e *CacheEntry) size() int64 {
+	n := int64(len(e.Body))
+	for k, vs := range e.Header {
+		for _, v := range vs {
+			n += int64(len(k) + len(v))
+		}
+	}
+	return n
+}
+
+// CacheStore 缓存存储，实现须并发安全
+type CacheStore interface {
+	Get(key string) (*CacheEntry, bool)
+	Set(key string, entry *CacheEntry)
+	Delete(key string)
+}
+
+// LRUCacheStore 按总大小淘汰最久未使用条目的内存缓存
+type LRUCacheStore struct {
+	maxBytes int64
+	mutex    sync.Mutex
+	size     int64
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type lruItem struct {
+	key   string
+	entry *CacheEntry
+	size  int64
+}
+
+// NewLRUCacheStore 创建内存缓存，maxBytes 为响应体和响应头的总大小上限
+func NewLRUCacheStore(maxBytes int64) *LRUCacheStore {
+	return &LRUCacheStore{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (s *LRUCacheStore) Get(key string) (*CacheEntry, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	e, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+	s.ll.MoveToFront(e)
+	return e.Value.(*lruItem).entry, true
+}
+
+func (s *LRUCacheStore) Set(key string, entry *CacheEntry) {
+	size := entry.size()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.removeLocked(key)
+	if size > s.maxBytes {
+		return
+	}
+	s.items[key] = s.ll.PushFront(&lruItem{key: key, entry: entry, size: size})
+	s.size += size
+	for s.size > s.maxBytes {
+		s.removeLocked(s.ll.Back().Value.(*lruItem).key)
+	}
+}
+
+func (s *LRUCacheStore) Delete(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.removeLocked(key)
+}
+
+func (s *LRUCacheStore) removeLocked(key string) {
+	e, ok := s.items[key]
+	if !ok {
+		return
+	}
+	s.ll.Remove(e)
+	delete(s.items, key)
+	s.size -= e.Value.(*lruItem).size
+}
+
+// Len 返回缓存条目数
+func (s *LRUCacheStore) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.ll.Len()
+}
+
+// CacheOptions 响应缓存选项
+type CacheOptions struct {
+	Store        CacheStore    // 缓存存储，默认 32MB 的内存 LRU 缓存
+	MaxEntrySize int64         // 单个响应体的大小上限，超过时不缓存，默认1MB
+	StaleIfError time.Duration // 上游请求失败或返回 5xx 时，允许返回过期不超过该时长的缓存，0 表示不启用
+}
+
+// WithCache 为 GET 请求启用响应缓存，遵循 Cache-Control、Expires，并通过 ETag、Last-Modified 重新验证。
+// 返回的响应通过 X-Cache 响应头标明命中情况。带有 Authorization 或 Cookie 请求头的请求按凭证分别缓存。
+func WithCache(opts CacheOptions) Option {
+	return func(w *HttpClientWrapper) {
+		if opts.Store == nil {
+			opts.Store = NewLRUCacheStore(32 << 20)
+		}
+		if opts.MaxEntrySize <= 0 {
+			opts.MaxEntrySize = 1 << 20
+		}
+		w.cache = &httpCache{opts: opts, clock: SystemClock()}
+	}
+}
+
+type httpCache struct {
+	opts  CacheOptions
+	clock Clock
+}
+
+// do 发送可缓存的请求，send 为实际发送请求的函数
+func (c *httpCache) do(req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	reqCC := parseCacheControl(req.Header.Get("Cache-Control"))
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" || reqCC.has("no-store") || isTransfer(req.Context()) {
+		return send(req)
+	}
+
+	key := cacheKey(req)
+	entry, ok := c.opts.Store.Get(key)
+	if ok && !entry.matches(req) {
+		ok = false
+	}
+	now := c.clock.Now()
+	if ok && !reqCC.has("no-cache") && entry.fresh(now) {
+		return entry.response(req, CacheHit), nil
+	}
+
+	// 设置重新验证的条件请求头，调用方已设置时保持不变
+	conditional := false
+	if ok {
+		if etag := entry.Header.Get("ETag"); etag != "" && req.Header.Get("If-None-Match") == "" {
+			req.Header.Set("If-None-Match", etag)
+			conditional = true
+		}
+		if lm := entry.Header.Get("Last-Modified"); lm != "" && req.Header.Get("If-Modified-Since") == "" {
+			req.Header.Set("If-Modified-Since", lm)
+			conditional = true
+		}
+	}
+
+	resp, err := send(req)
+	if err != nil || resp.StatusCode >= 500 {
+		if ok && c.canServeStale(entry, now) {
+			if err == nil {
+				drainBody(resp)
+			}
+			GetLogger().Warnf("请求 %s 失败，返回过期缓存: %v", req.URL, errOrStatus(resp, err))
+			return entry.response(req, CacheStale), nil
+		}
+		return resp, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && ok && conditional {
+		drainBody(resp)
+		updated := *entry
+		updated.Header = entry.Header.Clone()
+		for k, vs := range resp.Header {
+			updated.Header[k] = vs
+		}
+		updated.StoredAt = now
+		c.opts.Store.Set(key, &updated)
+		return updated.response(req, CacheRevalidated), nil
+	}
+
+	if resp.StatusCode != http.StatusOK || !isCacheable(resp) {
+		return resp, nil
+	}
+	return c.store(key, req, resp, now)
+}
+
+// cacheKey 返回请求的缓存键。请求带有 Authorization 或 Cookie 请求头时在地址后附加凭证的摘要，
+// 避免将一个调用方的私有响应返回给使用其他凭证的调用方
+func cacheKey(req *http.Request) string {
+	key := req.URL.String()
+	auth, cookie := req.Header.Values("Authorization"), req.Header.Values("Cookie")
+	if len(auth) == 0 && len(cookie) == 0 {
+		return key
+	}
+	h := sha256.New()
+	for _, v := range auth {
+		h.Write([]byte(v))
+		h.Write([]byte{0})
+	}
+	h.Write([]byte{1})
+	for _, v := range cookie {
+		h.Write([]byte(v))
+		h.Write([]byte{0})
+	}
+	return key + "#" + hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// store 读取响应体并缓存，响应体超过大小上限时不缓存
+func (c *httpCache) store(key string, req *http.Request, resp *http.Response, now time.Time) (*http.Response, error) {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, c.opts.MaxEntrySize+1))
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("读取响应体失败: %v", err)
+	}
+	if int64(len(body)) > c.opts.MaxEntrySize {
+		resp.Body = &readCloser{Reader: io.MultiReader(bytes.NewReader(body), resp.Body), Closer: resp.Body}
+		return resp, nil
+	}
+	_ = resp.Body.Close()
+
+	entry := &CacheEntry{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+		Body:       body,
+		StoredAt:   now,
+		Vary:       make(http.Header),
+	}
+	for _, name := range varyHeaders(resp) {
+		entry.Vary[name] = req.Header.Values(name)
+	}
+	c.opts.Store.Set(key, entry)
+	return entry.response(req, CacheMiss), nil
+}
+
+func (c *httpCache) canServeStale(entry *CacheEntry, now time.Time) bool {
+	staleIfError := c.opts.StaleIfError
+	if d, ok := parseCacheControl(entry.Header.Get("Cache-Control")).seconds("stale-if-error"); ok && d > staleIfError {
+		staleIfError = d
+	}
+	return staleIfError > 0 && now.Sub(entry.StoredAt)-entry.lifetime() <= staleIfError
+}
+
+// lifetime 返回缓存的有效期，优先使用 max-age，其次为 Expires 与 Date 之差
+func (e *CacheEntry) lifetime() time.Duration {
+	cc := parseCacheControl(e.Header.Get("Cache-Control"))
+	if cc.has("no-cache") {
+		return 0
+	}
+	if d, ok := cc.seconds("max-age"); ok {
+		return d
+	}
+	if expires := e.Header.Get("Expires"); expires != "" {
+		exp, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		date, err := http.ParseTime(e.Header.Get("Date"))
+		if err != nil {
+			date = e.StoredAt
+		}
+		return max(exp.Sub(date), 0)
+	}
+	return 0
+}
+
+// fresh 判断缓存是否仍在有效期内，Age 响应头计入已缓存时间
+func (e *CacheEntry) fresh(now time.Time) bool {
+	age := now.Sub(e.StoredAt)
+	if seconds, err := strconv.Atoi(e.Header.Get("Age")); err == nil && seconds > 0 {
+		age += time.Duration(seconds) * time.Second
+	}
+	return age < e.lifetime()
+}
+
+// matches 判断请求的 Vary 请求头是否与缓存时一致
+func (e *CacheEntry) matches(req *http.Request) bool {
+	for name, values := range e.Vary {
+		if strings.Join(req.Header.Values(name), ",") != strings.Join(values, ",") {
+			return false
+		}
+	}
+	return true
+}
+
+// response 以缓存内容构造响应
+func (e *CacheEntry) response(req *http.Request, status string) *http.Response {
+	header := e.Header.Clone()
+	header.Set(cacheStatusHeader, status)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+func isCacheable(resp *http.Response) bool {
+	if parseCacheControl(resp.Header.Get("Cache-Control")).has("no-store") {
+		return false
+	}
+	for _, name := range varyHeaders(resp) {
+		if name == "*" {
+			return false
+		}
+	}
+	return true
+}
+
+func varyHeaders(resp *http.Response) []string {
+	var names []string
+	for _, v := range resp.Header.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
+
+func errOrStatus(resp *http.Response, err error) any {
+	if err != nil {
+		return err
+	}
+	return resp.Status
+}
+
+// cacheControl 解析后的 Cache-Control 指令
+type cacheControl map[string]string
+
+func parseCacheControl(value string) cacheControl {
+	cc := make(cacheControl)
+	for _, directive := range strings.Split(value, ",") {
+		directive = strings.TrimSpace(directive)
+		if directive == "" {
+			continue
+		}
+		name, val, _ := strings.Cut(directive, "=")
+		cc[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(val), `"`)
+	}
+	return cc
+}
+
+func (cc cacheControl) has(name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+func (cc cacheControl) seconds(name string) (time.Duration, bool) {
+	v, ok := cc[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
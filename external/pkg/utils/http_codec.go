@@ -0,0 +1,355 @@
+package utils
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/vmihailenco/msgpack/v5"
+	"mime"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Codec 请求体和响应体的编解码器
+type Codec interface {
+	// ContentType 编码后请求体的 Content-Type
+	ContentType() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+var (
+	codecs     = make(map[string]Codec) // 按媒体类型注册的编解码器，由 codecsLock 保护
+	codecsLock sync.RWMutex
+)
+
+func init() {
+	RegisterCodec(JSONCodec{})
+	RegisterCodec(XMLCodec{}, "text/xml")
+	RegisterCodec(FormCodec{})
+	RegisterCodec(MsgpackCodec{}, "application/x-msgpack", "application/vnd.msgpack")
+	RegisterCodec(TextCodec{})
+	RegisterCodec(BytesCodec{})
+}
+
+// RegisterCodec 注册编解码器，同时注册到 ContentType 和 mediaTypes 指定的媒体类型下，已存在时覆盖
+func RegisterCodec(codec Codec, mediaTypes ...string) {
+	codecsLock.Lock()
+	defer codecsLock.Unlock()
+
+	for _, t := range append([]string{codec.ContentType()}, mediaTypes...) {
+		codecs[mediaTypeOf(t)] = codec
+	}
+}
+
+// GetCodec 根据 Content-Type 查找编解码器，未注册的 +json、+xml 后缀类型分别使用 JSON 和 XML 编解码器
+func GetCodec(contentType string) (Codec, bool) {
+	mediaType := mediaTypeOf(contentType)
+
+	codecsLock.RLock()
+	defer codecsLock.RUnlock()
+
+	if codec, ok := codecs[mediaType]; ok {
+		return codec, true
+	}
+	if i := strings.LastIndex(mediaType, "+"); i >= 0 {
+		codec, ok := codecs["application/"+mediaType[i+1:]]
+		return codec, ok
+	}
+	return nil, false
+}
+
+func mediaTypeOf(contentType string) string {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mediaType
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+// WithEncodedBody 使用 contentType 对应的编解码器将 v 编码为请求体
+func WithEncodedBody(contentType string, v any) RequestOption {
+	return func(c *requestConfig) {
+		codec, ok := GetCodec(contentType)
+		if !ok {
+			c.err = fmt.Errorf("未注册 %s 的编解码器", contentType)
+			return
+		}
+		body, err := codec.Marshal(v)
+		if err != nil {
+			c.err = fmt.Errorf("编码请求体失败: %v", err)
+			return
+		}
+		c.setBody(body, contentType)
+	}
+}
+
+// WithResponseCodec 使用 contentType 对应的编解码器解码响应体，忽略响应的 Content-Type
+func WithResponseCodec(contentType string) ResponseOption {
+	return func(c *responseConfig) {
+		c.contentType = contentType
+	}
+}
+
+// JSONCodec JSON 编解码器
+type JSONCodec struct{}
+
+func (JSONCodec) ContentType() string {
+	return "application/json"
+}
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// XMLCodec XML 编解码器
+type XMLCodec struct{}
+
+func (XMLCodec) ContentType() string {
+	return "application/xml"
+}
+
+func (XMLCodec) Marshal(v any) ([]byte, error) {
+	return xml.Marshal(v)
+}
+
+func (XMLCodec) Unmarshal(data []byte, v any) error {
+	return xml.Unmarshal(data, v)
+}
+
+// MsgpackCodec MessagePack 编解码器
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) ContentType() string {
+	return "application/msgpack"
+}
+
+func (MsgpackCodec) Marshal(v any) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (MsgpackCodec) Unmarshal(data []byte, v any) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+// TextCodec 纯文本编解码器，支持 string、[]byte 和 fmt.Stringer
+type TextCodec struct{}
+
+func (TextCodec) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+func (TextCodec) Marshal(v any) ([]byte, error) {
+	switch val := v.(type) {
+	case string:
+		return []byte(val), nil
+	case []byte:
+		return val, nil
+	case fmt.Stringer:
+		return []byte(val.String()), nil
+	}
+	return nil, fmt.Errorf("纯文本不支持类型 %T", v)
+}
+
+func (TextCodec) Unmarshal(data []byte, v any) error {
+	switch val := v.(type) {
+	case *string:
+		*val = string(data)
+	case *[]byte:
+		*val = append((*val)[:0], data...)
+	default:
+		return fmt.Errorf("纯文本不支持解码到类型 %T", v)
+	}
+	return nil
+}
+
+// BytesCodec 原始字节编解码器，只支持 []byte
+type BytesCodec struct{}
+
+func (BytesCodec) ContentType() string {
+	return "application/octet-stream"
+}
+
+func (BytesCodec) Marshal(v any) ([]byte, error) {
+	if b, ok := v.([]byte); ok {
+		return b, nil
+	}
+	return nil, fmt.Errorf("原始字节不支持类型 %T", v)
+}
+
+func (BytesCodec) Unmarshal(data []byte, v any) error {
+	if b, ok := v.(*[]byte); ok {
+		*b = append((*b)[:0], data...)
+		return nil
+	}
+	return fmt.Errorf("原始字节不支持解码到类型 %T", v)
+}
+
+// FormCodec 表单编解码器，支持 url.Values、map[string]string 以及带 form 标签的结构体，
+// 结构体字段支持字符串、布尔、数字及其切片，未设置 form 标签时使用字段名，标签为 "-" 时忽略
+type FormCodec struct{}
+
+func (FormCodec) ContentType() string {
+	return "application/x-www-form-urlencoded"
+}
+
+func (FormCodec) Marshal(v any) ([]byte, error) {
+	switch val := v.(type) {
+	case url.Values:
+		return []byte(val.Encode()), nil
+	case map[string]string:
+		form := make(url.Values, len(val))
+		for k, s := range val {
+			form.Set(k, s)
+		}
+		return []byte(form.Encode()), nil
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("表单不支持类型 %T", v)
+	}
+	form := make(url.Values)
+	for i := 0; i < rv.NumField(); i++ {
+		name, ok := formFieldName(rv.Type().Field(i))
+		if !ok {
+			continue
+		}
+		field := rv.Field(i)
+		if field.Kind() == reflect.Slice {
+			for j := 0; j < field.Len(); j++ {
+				s, err := formatFormValue(field.Index(j))
+				if err != nil {
+					return nil, err
+				}
+				form.Add(name, s)
+			}
+			continue
+		}
+		s, err := formatFormValue(field)
+		if err != nil {
+			return nil, err
+		}
+		form.Set(name, s)
+	}
+	return []byte(form.Encode()), nil
+}
+
+func (FormCodec) Unmarshal(data []byte, v any) error {
+	form, err := url.ParseQuery(string(data))
+	if err != nil {
+		return fmt.Errorf("解析表单失败: %v", err)
+	}
+	switch val := v.(type) {
+	case *url.Values:
+		*val = form
+		return nil
+	case *map[string]string:
+		*val = make(map[string]string, len(form))
+		for k := range form {
+			(*val)[k] = form.Get(k)
+		}
+		return nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("表单不支持解码到类型 %T", v)
+	}
+	rv = rv.Elem()
+	for i := 0; i < rv.NumField(); i++ {
+		name, ok := formFieldName(rv.Type().Field(i))
+		if !ok {
+			continue
+		}
+		values, ok := form[name]
+		if !ok {
+			continue
+		}
+		field := rv.Field(i)
+		if field.Kind() == reflect.Slice {
+			slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+			for j, s := range values {
+				if err = parseFormValue(slice.Index(j), s); err != nil {
+					return fmt.Errorf("解析表单字段 %s 失败: %v", name, err)
+				}
+			}
+			field.Set(slice)
+			continue
+		}
+		if err = parseFormValue(field, values[0]); err != nil {
+			return fmt.Errorf("解析表单字段 %s 失败: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func formFieldName(f reflect.StructField) (string, bool) {
+	if !f.IsExported() {
+		return "", false
+	}
+	name, _, _ := strings.Cut(f.Tag.Get("form"), ",")
+	switch name {
+	case "-":
+		return "", false
+	case "":
+		return f.Name, true
+	}
+	return name, true
+}
+
+func formatFormValue(v reflect.Value) (string, error) {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("表单不支持字段类型 %s", v.Type())
+}
+
+func parseFormValue(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("不支持字段类型 %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// HTTPError 中最多保留的响应体字节数
+const maxErrorBodySize = 4096
+
+// HTTPError 响应状态码不是 2xx 时返回的错误，可通过 errors.As 获取
+type HTTPError struct {
+	StatusCode int
+	Method     string
+	URL        string
+	Header     http.Header
+	Body       []byte // 响应体，超过 4KB 时被截断
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("请求 %s %s 失败，状态码: %d，响应: %s", e.Method, e.URL, e.StatusCode, e.Body)
+}
+
+// newHTTPError 根据响应创建错误，body 为已读取的完整响应体
+func newHTTPError(resp *http.Response, body []byte) *HTTPError {
+	e := &HTTPError{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+	}
+	if len(e.Body) > maxErrorBodySize {
+		e.Body = e.Body[:maxErrorBodySize]
+	}
+	if resp.Request != nil {
+		e.Method = resp.Request.Method
+		e.URL = resp.Request.URL.String()
+	}
+	return e
+}
+
+// ResponseOption 处理响应的选项
+type ResponseOption func(*responseConfig)
+
+type responseConfig struct {
+	errorBody   any
+	contentType string // 解码响应体使用的编解码器，为空时根据响应的 Content-Type 选择
+}
+
+// WithErrorBody 响应状态码不是 2xx 时将响应体解码到 v（须为指针），解码失败不影响返回的 *HTTPError
+func WithErrorBody(v any) ResponseOption {
+	return func(c *responseConfig) {
+		c.errorBody = v
+	}
+}
+
+// codec 选择解码响应体的编解码器，未注册的类型按 JSON 解码
+func (c *responseConfig) codec(resp *http.Response) Codec {
+	contentType := c.contentType
+	if contentType == "" {
+		contentType = resp.Header.Get("Content-Type")
+	}
+	if codec, ok := GetCodec(contentType); ok {
+		return codec
+	}
+	return JSONCodec{}
+}
+
+// decode 解码响应体。未指定编解码器时，string 和 []byte 类型在响应为 text/* 或 application/octet-stream 时直接接收原始响应体，
+// 其他情况与其他类型一样按编解码器解码，纯文本或原始字节响应按 JSON 解码，兼容未正确设置 Content-Type 的服务端
+func (c *responseConfig) decode(resp *http.Response, body []byte, v any) error {
+	codec := c.codec(resp)
+	if c.contentType == "" {
+		switch v.(type) {
+		case *string, *[]byte:
+			if isRawMediaType(resp.Header.Get("Content-Type")) {
+				return TextCodec{}.Unmarshal(body, v)
+			}
+		}
+		switch codec.(type) {
+		case TextCodec, BytesCodec:
+			codec = JSONCodec{}
+		}
+	}
+	return codec.Unmarshal(body, v)
+}
+
+// isRawMediaType 判断响应体是否为纯文本或原始字节
+func isRawMediaType(contentType string) bool {
+	mediaType := mediaTypeOf(contentType)
+	return strings.HasPrefix(mediaType, "text/") || mediaType == "application/octet-stream"
+}
+
+// decodeErrorBody 解码错误响应体
+func (c *responseConfig) decodeErrorBody(resp *http.Response, body []byte) {
+	if c.errorBody == nil || len(body) == 0 {
+		return
+	}
+	if err := c.decode(resp, body, c.errorBody); err != nil {
+		GetLogger().Warnf("解码错误响应体失败: %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"golang.org/x/time/rate"
+	"io"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimit 令牌桶限流和并发上限配置，零值字段表示不限制
+type RateLimit struct {
+	QPS         float64 // 每秒放行的请求数
+	Burst       int     // 令牌桶容量，即允许的突发请求数，默认为 QPS 向上取整
+	MaxInFlight int     // 同时进行中的请求数上限，响应体关闭后释放
+}
+
+// requestLimiter 按 RateLimit 限流，limiter 和 slots 为nil时表示对应项不限制
+type requestLimiter struct {
+	limiter *rate.Limiter
+	slots   chan struct{}
+}
+
+func newRequestLimiter(limit RateLimit) *requestLimiter {
+	l := &requestLimiter{}
+	if limit.QPS > 0 {
+		burst := limit.Burst
+		if burst <= 0 {
+			burst = int(math.Ceil(limit.QPS))
+		}
+		l.limiter = rate.NewLimiter(rate.Limit(limit.QPS), burst)
+	}
+	if limit.MaxInFlight > 0 {
+		l.slots = make(chan struct{}, limit.MaxInFlight)
+	}
+	return l
+}
+
+// wait 等待令牌，上下文结束时归还预占的令牌并返回上下文的错误
+func (l *requestLimiter) wait(ctx context.Context) error {
+	if l.limiter == nil {
+		return nil
+	}
+	r := l.limiter.Reserve()
+	delay := r.Delay()
+	if delay == 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		r.Cancel()
+		return ctx.Err()
+	}
+}
+
+// acquire 等待并发名额
+func (l *requestLimiter) acquire(ctx context.Context) error {
+	if l.slots == nil {
+		return nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *requestLimiter) release() {
+	if l.slots != nil {
+		<-l.slots
+	}
+}
+
+// WithRateLimit 限制客户端整体的请求速率和并发数，每次重试同样计入
+func WithRateLimit(limit RateLimit) Option {
+	return func(w *HttpClientWrapper) {
+		w.limiter = newRequestLimiter(limit)
+	}
+}
+
+// WithPerHostRateLimit 为每个目标主机分别限制请求速率和并发数，WithHostRateLimit 单独配置的主机除外
+func WithPerHostRateLimit(limit RateLimit) Option {
+	return func(w *HttpClientWrapper) {
+		w.hostLimit = &limit
+	}
+}
+
+// WithHostRateLimit 限制目标主机 host（含端口时须一致，如 api.example.com:8443）的请求速率和并发数
+func WithHostRateLimit(host string, limit RateLimit) Option {
+	return func(w *HttpClientWrapper) {
+		if w.hostLimits == nil {
+			w.hostLimits = make(map[string]RateLimit)
+		}
+		w.hostLimits[host] = limit
+	}
+}
+
+// hostLimiter 返回目标主机的限流器，未配置时返回nil
+func (w *HttpClientWrapper) hostLimiter(host string) *requestLimiter {
+	limit, ok := w.hostLimits[host]
+	if !ok {
+		if w.hostLimit == nil {
+			return nil
+		}
+		limit = *w.hostLimit
+	}
+
+	w.limitersLock.Lock()
+	defer w.limitersLock.Unlock()
+
+	l, ok := w.hostLimiters[host]
+	if !ok {
+		l = newRequestLimiter(limit)
+		w.hostLimiters[host] = l
+	}
+	return l
+}
+
+// acquire 依次等待客户端和目标主机的令牌及并发名额，返回的 release 用于释放并发名额。
+// 等待时间计入 LimitWaitStats，上下文结束时返回错误并释放已获取的名额。
+func (w *HttpClientWrapper) acquire(req *http.Request) (release func(), err error) {
+	var limiters []*requestLimiter
+	if w.limiter != nil {
+		limiters = append(limiters, w.limiter)
+	}
+	if l := w.hostLimiter(req.URL.Host); l != nil {
+		limiters = append(limiters, l)
+	}
+	if len(limiters) == 0 {
+		return func() {}, nil
+	}
+
+	ctx := req.Context()
+	start := time.Now()
+	defer func() {
+		w.limitWait.Observe(time.Since(start))
+	}()
+	for _, l := range limiters {
+		if err = l.wait(ctx); err != nil {
+			return nil, fmt.Errorf("等待限流失败: %w", err)
+		}
+	}
+	for i, l := range limiters {
+		if err = l.acquire(ctx); err != nil {
+			for _, acquired := range limiters[:i] {
+				acquired.release()
+			}
+			return nil, fmt.Errorf("等待并发名额失败: %w", err)
+		}
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			for _, l := range limiters {
+				l.release()
+			}
+		})
+	}, nil
+}
+
+// LimitWaitStats 返回请求等待限流和并发名额的耗时分布
+func (w *HttpClientWrapper) LimitWaitStats() HistogramSnapshot {
+	return w.limitWait.Snapshot()
+}
+
+// releaseOnClose 关闭响应体时释放并发名额
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+}
+
+func (r *releaseOnClose) Close() error {
+	defer r.release()
+	return r.ReadCloser.Close()
+}
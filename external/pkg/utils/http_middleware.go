@@ -0,0 +1,286 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// RoundTripFunc 发送单次请求并返回响应
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip 实现 http.RoundTripper
+func (f RoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware 请求拦截器，包装 next 以在请求前后插入逻辑。
+// 拦截器作用于每次实际发送的请求，重试时会再次执行。
+type Middleware func(next RoundTripFunc) RoundTripFunc
+
+// Use 添加拦截器，先添加的拦截器位于外层，最先处理请求、最后处理响应
+func (w *HttpClientWrapper) Use(mws ...Middleware) {
+	w.middlewareLock.Lock()
+	defer w.middlewareLock.Unlock()
+
+	w.middlewares = append(w.middlewares, mws...)
+	chain := RoundTripFunc(w.send)
+	for i := len(w.middlewares) - 1; i >= 0; i-- {
+		chain = w.middlewares[i](chain)
+	}
+	w.chain = chain
+}
+
+// roundTrip 经过拦截器链发送单次请求
+func (w *HttpClientWrapper) roundTrip(req *http.Request) (*http.Response, error) {
+	w.middlewareLock.RLock()
+	chain := w.chain
+	w.middlewareLock.RUnlock()
+
+	if chain == nil {
+		return w.send(req)
+	}
+	return chain(req)
+}
+
+// send 通过 http.Client 发送请求，Upload、Download 的请求不受客户端超时限制
+func (w *HttpClientWrapper) send(req *http.Request) (*http.Response, error) {
+	if isTransfer(req.Context()) {
+		return w.transferClient.Do(req)
+	}
+	return w.client.Do(req)
+}
+
+type requestIDKey struct{}
+
+// ContextWithRequestID 将请求ID放入上下文，经 RequestIDMiddleware 传递给下游
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext 从上下文中获取请求ID
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok && id != ""
+}
+
+// RequestIDMiddleware 在请求头 header 中传递请求ID，header 为空时使用 X-Request-ID。
+// 请求头已设置时保持不变，否则取上下文中的请求ID，都没有时生成新的ID。
+func RequestIDMiddleware(header string) Middleware {
+	if header == "" {
+		header = "X-Request-ID"
+	}
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get(header) == "" {
+				id, ok := RequestIDFromContext(req.Context())
+				if !ok {
+					id = newRequestID()
+				}
+				req.Header.Set(header, id)
+			}
+			return next(req)
+		}
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// 默认脱敏的请求头和响应头
+var defaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// LoggingOptions 请求日志选项
+type LoggingOptions struct {
+	MaxBodySize   int      // 请求体和响应体最多输出的字节数，默认1024，小于0表示不输出
+	RedactHeaders []string // 需要脱敏的请求头和响应头，默认 Authorization、Cookie 等认证相关请求头
+}
+
+// LoggingMiddleware 以 Debug 级别记录请求和响应，超出长度的请求体和响应体被截断，
+// 敏感请求头被脱敏，只输出文本类型的响应体
+func LoggingMiddleware(opts LoggingOptions) Middleware {
+	if opts.MaxBodySize == 0 {
+		opts.MaxBodySize = 1024
+	}
+	if opts.RedactHeaders == nil {
+		opts.RedactHeaders = defaultRedactHeaders
+	}
+	redact := make(map[string]bool, len(opts.RedactHeaders))
+	for _, h := range opts.RedactHeaders {
+		redact[http.CanonicalHeaderKey(h)] = true
+	}
+
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			GetLogger().Debugf("发送HTTP请求-method:%s url:%s header:%v body:%s",
+				req.Method, req.URL, redactHeader(req.Header, redact), requestBodyForLog(req, opts.MaxBodySize))
+
+			start := time.Now()
+			resp, err := next(req)
+			latency := time.Since(start)
+			if err != nil {
+				GetLogger().Debugf("HTTP请求失败-method:%s url:%s latency:%v err:%v", req.Method, req.URL, latency, err)
+				return nil, err
+			}
+
+			GetLogger().Debugf("收到HTTP响应-method:%s url:%s status:%d latency:%v header:%v body:%s",
+				req.Method, req.URL, resp.StatusCode, latency, redactHeader(resp.Header, redact), responseBodyForLog(resp, opts.MaxBodySize))
+			return resp, nil
+		}
+	}
+}
+
+func redactHeader(header http.Header, redact map[string]bool) http.Header {
+	h := make(http.Header, len(header))
+	for k, vs := range header {
+		if redact[k] {
+			h[k] = []string{"***"}
+		} else {
+			h[k] = vs
+		}
+	}
+	return h
+}
+
+// requestBodyForLog 通过 GetBody 读取请求体副本，不影响实际发送
+func requestBodyForLog(req *http.Request, limit int) string {
+	if limit < 0 || req.Body == nil || req.Body == http.NoBody {
+		return ""
+	}
+	if !isTextContent(req.Header.Get("Content-Type")) {
+		return fmt.Sprintf("<%s %d bytes>", req.Header.Get("Content-Type"), req.ContentLength)
+	}
+	if req.GetBody == nil {
+		return "<stream>"
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return fmt.Sprintf("<读取请求体失败: %v>", err)
+	}
+	defer body.Close()
+	peek, _ := io.ReadAll(io.LimitReader(body, int64(limit)+1))
+	return truncateForLog(peek, limit)
+}
+
+// responseBodyForLog 读取响应体的前 limit 字节用于日志，并将其放回响应体
+func responseBodyForLog(resp *http.Response, limit int) string {
+	if limit < 0 || resp.Body == nil || resp.Body == http.NoBody {
+		return ""
+	}
+	if !isTextContent(resp.Header.Get("Content-Type")) {
+		return fmt.Sprintf("<%s %d bytes>", resp.Header.Get("Content-Type"), resp.ContentLength)
+	}
+	br := bufio.NewReaderSize(resp.Body, limit+1)
+	peek, err := br.Peek(limit + 1)
+	resp.Body = &readCloser{Reader: br, Closer: resp.Body}
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return fmt.Sprintf("<读取响应体失败: %v>", err)
+	}
+	return truncateForLog(peek, limit)
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func truncateForLog(body []byte, limit int) string {
+	if len(body) > limit {
+		return string(body[:limit]) + "...(truncated)"
+	}
+	return string(body)
+}
+
+// isTextContent 判断内容类型是否为可直接输出的文本
+func isTextContent(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "json") ||
+		strings.HasSuffix(mediaType, "xml") || mediaType == "application/x-www-form-urlencoded"
+}
+
+// HTTPMetrics HTTP 请求指标，通过 Middleware 采集
+type HTTPMetrics struct {
+	requests atomic.Int64
+	errors   atomic.Int64
+	statuses [6]atomic.Int64 // 按状态码类别计数，下标为状态码首位数字
+	latency  *Histogram
+}
+
+// NewHTTPMetrics 创建 HTTP 请求指标
+func NewHTTPMetrics() *HTTPMetrics {
+	return &HTTPMetrics{latency: NewHistogram(defaultLatencyBuckets)}
+}
+
+// Middleware 返回采集请求数、错误数、状态码和耗时的拦截器
+func (m *HTTPMetrics) Middleware() Middleware {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next(req)
+			m.latency.Observe(time.Since(start))
+			m.requests.Add(1)
+			if err != nil {
+				m.errors.Add(1)
+			} else if class := resp.StatusCode / 100; class > 0 && class < len(m.statuses) {
+				m.statuses[class].Add(1)
+			}
+			return resp, err
+		}
+	}
+}
+
+// HTTPMetricsSnapshot HTTP 请求指标快照
+type HTTPMetricsSnapshot struct {
+	Requests int64            // 请求总数
+	Errors   int64            // 网络错误等未收到响应的请求数
+	Statuses map[string]int64 // 按状态码类别（2xx、4xx 等）统计的请求数
+	Latency  HistogramSnapshot
+}
+
+// Snapshot 获取指标快照
+func (m *HTTPMetrics) Snapshot() HTTPMetricsSnapshot {
+	s := HTTPMetricsSnapshot{
+		Requests: m.requests.Load(),
+		Errors:   m.errors.Load(),
+		Statuses: make(map[string]int64),
+		Latency:  m.latency.Snapshot(),
+	}
+	for class := 1; class < len(m.statuses); class++ {
+		if n := m.statuses[class].Load(); n > 0 {
+			s.Statuses[fmt.Sprintf("%dxx", class)] = n
+		}
+	}
+	return s
+}
+
+// WritePrometheus 以 Prometheus 文本格式输出指标
+func (m *HTTPMetrics) WritePrometheus(w io.Writer, namespace string) error {
+	s := m.Snapshot()
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# HELP %s_requests_total 请求总数\n# TYPE %s_requests_total counter\n", namespace, namespace)
+	for class := 1; class < len(m.statuses); class++ {
+		code := fmt.Sprintf("%dxx", class)
+		fmt.Fprintf(bw, "%s_requests_total{code=\"%s\"} %d\n", namespace, code, s.Statuses[code])
+	}
+	fmt.Fprintf(bw, "# HELP %s_errors_total 未收到响应的请求数\n# TYPE %s_errors_total counter\n%s_errors_total %d\n",
+		namespace, namespace, namespace, s.Errors)
+	writePrometheusHistogram(bw, namespace+"_request_duration_seconds", "请求耗时", s.Latency)
+	return bw.Flush()
+}
@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// TransportConfig 连接池配置，零值字段使用默认值
+type TransportConfig struct {
+	MaxIdleConns          int           // 所有主机的最大空闲连接数，默认100
+	MaxIdleConnsPerHost   int           // 每个主机的最大空闲连接数，默认32
+	MaxConnsPerHost       int           // 每个主机的最大连接数，0 表示不限制
+	IdleConnTimeout       time.Duration // 空闲连接超时时间，默认90秒
+	DialTimeout           time.Duration // 建立连接超时时间，默认5秒
+	KeepAlive             time.Duration // TCP keep-alive 间隔，默认30秒
+	TLSHandshakeTimeout   time.Duration // TLS 握手超时时间，默认10秒
+	ResponseHeaderTimeout time.Duration // 等待响应头超时时间，0 表示不限制
+	DisableHTTP2          bool          // 禁用 HTTP/2
+	// Proxy 代理设置，默认读取 HTTP_PROXY、HTTPS_PROXY、NO_PROXY 环境变量
+	Proxy func(*http.Request) (*url.URL, error)
+}
+
+// NewTransport 根据配置创建 http.Transport，同一个 Transport 应在多个客户端间共享以复用连接
+func NewTransport(config TransportConfig) *http.Transport {
+	if config.MaxIdleConns <= 0 {
+		config.MaxIdleConns = 100
+	}
+	if config.MaxIdleConnsPerHost <= 0 {
+		config.MaxIdleConnsPerHost = 32
+	}
+	if config.IdleConnTimeout <= 0 {
+		config.IdleConnTimeout = 90 * time.Second
+	}
+	if config.DialTimeout <= 0 {
+		config.DialTimeout = 5 * time.Second
+	}
+	if config.KeepAlive <= 0 {
+		config.KeepAlive = 30 * time.Second
+	}
+	if config.TLSHandshakeTimeout <= 0 {
+		config.TLSHandshakeTimeout = 10 * time.Second
+	}
+	if config.Proxy == nil {
+		config.Proxy = http.ProxyFromEnvironment
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   config.DialTimeout,
+		KeepAlive: config.KeepAlive,
+	}
+	return &http.Transport{
+		Proxy:                 config.Proxy,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     !config.DisableHTTP2,
+		MaxIdleConns:          config.MaxIdleConns,
+		MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       config.MaxConnsPerHost,
+		IdleConnTimeout:       config.IdleConnTimeout,
+		TLSHandshakeTimeout:   config.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: config.ResponseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// 未指定 Transport 的客户端共享的默认连接池
+var defaultTransport = NewTransport(TransportConfig{})
+
+// WithTransport 设置客户端使用的 RoundTripper，默认使用共享的连接池
+func WithTransport(transport http.RoundTripper) Option {
+	return func(w *HttpClientWrapper) {
+		w.transport = transport
+	}
+}
+
+// ClientRegistry 客户端注册表，按域名和名称复用 HttpClientWrapper，所有客户端共享同一个连接池
+type ClientRegistry struct {
+	transport  *http.Transport
+	maxClients int // 最多保留的客户端数，0 表示不限制
+	mutex      sync.Mutex
+	clients    map[clientKey]*HttpClientWrapper
+}
+
+type clientKey struct {
+	domain string
+	name   string
+}
+
+// NewClientRegistry 创建客户端注册表
+func NewClientRegistry(config TransportConfig) *ClientRegistry {
+	return &ClientRegistry{
+		transport: NewTransport(config),
+		clients:   make(map[clientKey]*HttpClientWrapper),
+	}
+}
+
+// Get 返回 domain 和 name 对应的客户端，不存在时以 opts 创建。
+// name 用于区分同一域名下配置不同的客户端，已存在的客户端不再应用 opts。
+// NewClientRegistry 创建的注册表不限制客户端数，域名由外部输入决定时应自行控制 domain 和 name 的取值范围。
+func (r *ClientRegistry) Get(domain, name string, opts ...Option) *HttpClientWrapper {
+	key := clientKey{domain: domain, name: name}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if w, ok := r.clients[key]; ok {
+		return w
+	}
+	if r.maxClients > 0 && len(r.clients) >= r.maxClients {
+		// 随机淘汰一个客户端，被淘汰的客户端仍可使用，其熔断和限流状态不再被复用
+		for k := range r.clients {
+			delete(r.clients, k)
+			break
+		}
+	}
+	w := NewHttpClientWrapper(domain, append([]Option{WithTransport(r.transport)}, opts...)...)
+	r.clients[key] = w
+	return w
+}
+
+// CloseIdleConnections 关闭连接池中的空闲连接
+func (r *ClientRegistry) CloseIdleConnections() {
+	r.transport.CloseIdleConnections()
+}
+
+// DoRequest 使用的客户端注册表最多保留的客户端数，避免访问大量不同域名时无限增长
+const defaultClientsLimit = 256
+
+// DoRequest 使用的客户端注册表
+var defaultClients = &ClientRegistry{
+	transport:  defaultTransport,
+	maxClients: defaultClientsLimit,
+	clients:    make(map[clientKey]*HttpClientWrapper),
+}
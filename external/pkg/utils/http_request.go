@@ -0,0 +1,193 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// RequestOption 单次请求的选项
+type RequestOption func(*requestConfig)
+
+type requestConfig struct {
+	header      http.Header
+	query       url.Values
+	body        []byte
+	hasBody     bool
+	contentType string
+	timeout     time.Duration
+	err         error // 构造请求体等选项失败时记录，Do 直接返回该错误
+
+	bodyReader  io.Reader // 流式请求体，设置时忽略 body
+	progress    ProgressFunc
+	checksum    hash.Hash
+	expectedSum string
+	rangeOffset int64
+}
+
+func newRequestConfig(opts []RequestOption) *requestConfig {
+	cfg := &requestConfig{
+		header: make(http.Header),
+		query:  make(url.Values),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+func (c *requestConfig) setBody(body []byte, contentType string) {
+	c.body = body
+	c.hasBody = true
+	c.contentType = contentType
+}
+
+// WithHeader 设置请求头，同名请求头被覆盖
+func WithHeader(key, value string) RequestOption {
+	return func(c *requestConfig) {
+		c.header.Set(key, value)
+	}
+}
+
+// WithHeaders 批量设置请求头
+func WithHeaders(header map[string]string) RequestOption {
+	return func(c *requestConfig) {
+		for k, v := range header {
+			c.header.Set(k, v)
+		}
+	}
+}
+
+// WithQuery 添加查询参数，与 api 中已有的查询参数合并
+func WithQuery(query url.Values) RequestOption {
+	return func(c *requestConfig) {
+		for k, vs := range query {
+			for _, v := range vs {
+				c.query.Add(k, v)
+			}
+		}
+	}
+}
+
+// WithBody 设置原始请求体，contentType 为空时不设置 Content-Type
+func WithBody(body []byte, contentType string) RequestOption {
+	return func(c *requestConfig) {
+		c.setBody(body, contentType)
+	}
+}
+
+// WithJSONBody 将 v 序列化为 JSON 作为请求体
+func WithJSONBody(v any) RequestOption {
+	return func(c *requestConfig) {
+		body, err := json.Marshal(v)
+		if err != nil {
+			c.err = fmt.Errorf("序列化JSON请求体失败: %v", err)
+			return
+		}
+		c.setBody(body, "application/json")
+	}
+}
+
+// WithFormBody 以 application/x-www-form-urlencoded 编码表单作为请求体
+func WithFormBody(form url.Values) RequestOption {
+	return func(c *requestConfig) {
+		c.setBody([]byte(form.Encode()), "application/x-www-form-urlencoded")
+	}
+}
+
+// MultipartFile multipart 请求中的文件
+type MultipartFile struct {
+	Field    string    // 表单字段名
+	FileName string    // 文件名
+	Reader   io.Reader // 文件内容
+}
+
+// WithMultipartBody 以 multipart/form-data 编码表单字段和文件作为请求体，文件内容在构造请求时全部读入内存
+func WithMultipartBody(fields map[string]string, files ...MultipartFile) RequestOption {
+	return func(c *requestConfig) {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		for k, v := range fields {
+			if err := mw.WriteField(k, v); err != nil {
+				c.err = fmt.Errorf("写入表单字段失败: %v", err)
+				return
+			}
+		}
+		for _, f := range files {
+			part, err := mw.CreateFormFile(f.Field, f.FileName)
+			if err != nil {
+				c.err = fmt.Errorf("创建文件表单失败: %v", err)
+				return
+			}
+			if _, err = io.Copy(part, f.Reader); err != nil {
+				c.err = fmt.Errorf("读取文件 %s 失败: %v", f.FileName, err)
+				return
+			}
+		}
+		if err := mw.Close(); err != nil {
+			c.err = fmt.Errorf("构造multipart请求体失败: %v", err)
+			return
+		}
+		c.setBody(buf.Bytes(), mw.FormDataContentType())
+	}
+}
+
+// WithBasicAuth 设置 Basic 认证
+func WithBasicAuth(username, password string) RequestOption {
+	return func(c *requestConfig) {
+		req := http.Request{Header: c.header}
+		req.SetBasicAuth(username, password)
+	}
+}
+
+// WithBearerToken 设置 Bearer 令牌
+func WithBearerToken(token string) RequestOption {
+	return func(c *requestConfig) {
+		c.header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// WithRequestTimeout 设置单次请求的超时时间，覆盖客户端的默认超时，包含重试和读取响应体的时间
+func WithRequestTimeout(timeout time.Duration) RequestOption {
+	return func(c *requestConfig) {
+		c.timeout = timeout
+	}
+}
+
+// buildURL 拼接请求地址并合并查询参数
+func (w *HttpClientWrapper) buildURL(api string, query url.Values) (string, error) {
+	apiURL := w.Domain + api
+	if len(query) == 0 {
+		return apiURL, nil
+	}
+	u, err := url.Parse(apiURL)
+	if err != nil {
+		return "", fmt.Errorf("解析请求地址失败: %v", err)
+	}
+	q := u.Query()
+	for k, vs := range query {
+		for _, v := range vs {
+			q.Add(k, v)
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// cancelOnClose 关闭响应体时释放单次请求超时的上下文
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
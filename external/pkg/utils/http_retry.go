@@ -0,0 +1,235 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy HTTP 请求重试策略
+type RetryPolicy interface {
+	// Retry 在第 attempt 次请求（从1开始）结束后调用，返回是否重试及重试前的等待时间。
+	// resp 与 err 有且只有一个非nil，返回不重试时 resp 原样返回给调用方。
+	Retry(req *http.Request, resp *http.Response, err error, attempt int) (time.Duration, bool)
+}
+
+// DefaultRetryPolicy 默认重试策略：对超时、连接重置等网络错误、429 和 5xx（501 除外）重试，
+// 退避时间为带抖动的指数退避，429/503 携带 Retry-After 时按其等待。
+// 非幂等请求（POST、PATCH 等）默认不重试，可通过 RetryNonIdempotent 开启，
+// 或为单个请求设置 Idempotency-Key 请求头。
+type DefaultRetryPolicy struct {
+	MaxAttempts        int           // 最大请求次数（含首次），小于等于1表示不重试
+	BaseDelay          time.Duration // 首次重试的基础间隔
+	MaxDelay           time.Duration // 最大间隔，Retry-After 超过该值时不再重试，0 表示不限制
+	RetryNonIdempotent bool          // 是否重试非幂等请求
+}
+
+func (p *DefaultRetryPolicy) Retry(req *http.Request, resp *http.Response, err error, attempt int) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts || !p.RetryNonIdempotent && !isIdempotent(req) {
+		return 0, false
+	}
+	if err != nil {
+		// 调用方取消或请求整体超时时不再重试
+		return p.backoff(attempt), req.Context().Err() == nil && isRetriableError(err)
+	}
+
+	if resp.StatusCode != http.StatusTooManyRequests && (resp.StatusCode < 500 || resp.StatusCode == http.StatusNotImplemented) {
+		return 0, false
+	}
+	delay := p.backoff(attempt)
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if after, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			if p.MaxDelay > 0 && after > p.MaxDelay {
+				return 0, false
+			}
+			delay = max(delay, after)
+		}
+	}
+	return delay, true
+}
+
+// backoff 计算第 attempt 次请求失败后的等待时间，在指数退避值的 [1/2, 1] 区间内随机
+func (p *DefaultRetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if half := delay / 2; half > 0 {
+		delay = half + rand.N(half+1)
+	}
+	return delay
+}
+
+// isIdempotent 判断请求是否幂等，与 net/http 的判断规则一致
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	_, hasKey := req.Header["Idempotency-Key"]
+	_, hasXKey := req.Header["X-Idempotency-Key"]
+	return hasKey || hasXKey
+}
+
+// isRetriableError 判断网络错误是否可重试：超时、连接被重置或意外关闭。
+// 域名不存在、连接被拒绝等通常不会自行恢复的错误不重试。
+func isRetriableError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return !dnsErr.IsNotFound && (dnsErr.IsTimeout || dnsErr.IsTemporary)
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE)
+}
+
+// parseRetryAfter 解析 Retry-After，支持秒数和 HTTP 日期两种格式
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+// WithRetryPolicy 设置重试策略，覆盖 WithRetry，nil 表示不重试
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(w *HttpClientWrapper) {
+		w.retryPolicy = policy
+	}
+}
+
+// RetryError 请求最终失败时返回的错误，记录请求次数和每次失败的原因。
+// 重试后最后一次响应仍为 429 或 5xx 时，最后一个错误为该响应的 *HTTPError，可通过 errors.As 获取。
+type RetryError struct {
+	Attempts int
+	Errors   []error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("请求失败（共尝试 %d 次）: %v", e.Attempts, e.Errors)
+}
+
+func (e *RetryError) Unwrap() []error {
+	return e.Errors
+}
+
+// doWithRetry 按重试策略发送请求，等待重试期间请求上下文结束时立即返回。
+// 重试用尽后仍为 429 或 5xx 时返回 *RetryError 而不是响应。
+// 启用限流时每次请求前等待令牌和并发名额；启用熔断时每次请求前检查目标主机的熔断器，熔断器打开时不再发送请求。
+func (w *HttpClientWrapper) doWithRetry(req *http.Request) (*http.Response, error) {
+	var errs []error
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			// 每次重试创建新请求体
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					errs = append(errs, fmt.Errorf("重建请求体失败: %v", err))
+					return nil, &RetryError{Attempts: attempt - 1, Errors: errs}
+				}
+				req.Body = body
+			}
+		}
+
+		// 先检查熔断器，熔断时立即失败，不等待限流和并发名额
+		var report func(breakerOutcome)
+		if b := w.breaker(req.URL.Host); b != nil {
+			var err error
+			if report, err = b.allow(); err != nil {
+				return nil, attemptError(err, attempt, errs)
+			}
+		}
+		release, err := w.acquire(req)
+		if err != nil {
+			if report != nil {
+				report(outcomeIgnored)
+			}
+			return nil, attemptError(err, attempt, errs)
+		}
+
+		resp, err := w.roundTrip(req)
+		if report != nil {
+			report(breakerResult(req, resp, err))
+		}
+		if err != nil {
+			release()
+			errs = append(errs, err)
+		} else {
+			resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: release}
+		}
+		var (
+			delay time.Duration
+			retry bool
+		)
+		if w.retryPolicy != nil {
+			delay, retry = w.retryPolicy.Retry(req, resp, err, attempt)
+		}
+		if retry && req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+			// 请求体无法重建，不能重试
+			retry = false
+		}
+		if !retry {
+			if err != nil {
+				return nil, &RetryError{Attempts: attempt, Errors: errs}
+			}
+			if attempt > 1 && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500) {
+				// 重试后仍失败，最后一次响应以 HTTPError 记录在返回的错误中
+				body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+				_ = resp.Body.Close()
+				errs = append(errs, newHTTPError(resp, body))
+				return nil, &RetryError{Attempts: attempt, Errors: errs}
+			}
+			// 未重试或重试后得到其他响应时返回最后一次的响应，由调用方处理状态码
+			return resp, nil
+		}
+		if resp != nil {
+			errs = append(errs, fmt.Errorf("服务端错误 %d", resp.StatusCode))
+			drainBody(resp)
+		}
+
+		GetLogger().Debugf("请求 %s %s 第 %d 次失败，%v 后重试", req.Method, req.URL, attempt, delay)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			errs = append(errs, req.Context().Err())
+			return nil, &RetryError{Attempts: attempt, Errors: errs}
+		}
+	}
+}
+
+// attemptError 返回第 attempt 次请求发出前的错误，首次请求时直接返回 err
+func attemptError(err error, attempt int, errs []error) error {
+	if attempt == 1 {
+		return err
+	}
+	return &RetryError{Attempts: attempt - 1, Errors: append(errs, err)}
+}
+
+func drainBody(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+}
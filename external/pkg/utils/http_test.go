@@ -0,0 +1,1116 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// echo 返回请求的方法、查询参数、请求头和请求体
+func echoServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if r.Method == http.MethodHead {
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"method": r.Method,
+			"query":  r.URL.RawQuery,
+			"header": r.Header,
+			"body":   string(body),
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+type echoResult struct {
+	Method string      `json:"method"`
+	Query  string      `json:"query"`
+	Header http.Header `json:"header"`
+	Body   string      `json:"body"`
+}
+
+func TestHttpClientWrapperDo(t *testing.T) {
+	srv := echoServer(t)
+	w := NewHttpClientWrapper(srv.URL, WithRetry(0, 0))
+	ctx := context.Background()
+
+	resp, err := w.Put(ctx, "/items?a=1", WithQuery(url.Values{"b": {"2"}}), WithJSONBody(map[string]int{"n": 1}), WithBearerToken("tk"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := HandleResponse[echoResult](resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Method != http.MethodPut || res.Query != "a=1&b=2" || res.Body != `{"n":1}` {
+		t.Errorf("请求内容不正确: %+v", res)
+	}
+	if res.Header.Get("Authorization") != "Bearer tk" || res.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("请求头不正确: %v", res.Header)
+	}
+
+	resp, err = w.Patch(ctx, "/items", WithFormBody(url.Values{"k": {"v"}}), WithBasicAuth("u", "p"), WithHeader("X-Test", "1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, err = HandleResponse[echoResult](resp); err != nil {
+		t.Fatal(err)
+	}
+	if res.Body != "k=v" || res.Header.Get("Content-Type") != "application/x-www-form-urlencoded" ||
+		res.Header.Get("X-Test") != "1" || !strings.HasPrefix(res.Header.Get("Authorization"), "Basic ") {
+		t.Errorf("表单请求不正确: %+v", res)
+	}
+
+	resp, err = w.Do(ctx, http.MethodPost, "/upload", WithMultipartBody(map[string]string{"name": "a"},
+		MultipartFile{Field: "file", FileName: "a.txt", Reader: strings.NewReader("hello")}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, err = HandleResponse[echoResult](resp); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(res.Header.Get("Content-Type"), "multipart/form-data") || !strings.Contains(res.Body, "hello") {
+		t.Errorf("multipart 请求不正确: %+v", res)
+	}
+
+	if resp, err = w.Delete(ctx, "/items/1"); err != nil {
+		t.Fatal(err)
+	}
+	if res, err = HandleResponse[echoResult](resp); err != nil || res.Method != http.MethodDelete || res.Header.Get("Content-Type") != "" {
+		t.Errorf("DELETE 请求不正确: %+v %v", res, err)
+	}
+	if resp, err = w.Head(ctx, "/items/1"); err != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("HEAD 请求失败: %v", err)
+	}
+	resp.Body.Close()
+
+	// 兼容旧接口，默认 Content-Type 为 JSON
+	if resp, err = w.Post("/legacy", map[string]string{"X-Test": "2"}, url.Values{"q": {"1"}}, []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	if res, err = HandleResponse[echoResult](resp); err != nil || res.Header.Get("Content-Type") != "application/json" || res.Query != "q=1" {
+		t.Errorf("旧接口请求不正确: %+v %v", res, err)
+	}
+	if resp, err = w.Get("/legacy", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if res, err = HandleResponse[echoResult](resp); err != nil || res.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("旧接口无请求体时也应发送 JSON Content-Type: %+v %v", res, err)
+	}
+
+	start := time.Now()
+	_, err = w.Do(ctx, http.MethodGet, "/slow", WithRequestTimeout(50*time.Millisecond))
+	if err == nil || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("请求应超时: %v", err)
+	}
+}
+
+func TestHttpClientWrapperRetry(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		switch r.URL.Path {
+		case "/flaky":
+			if n < 3 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		case "/down":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		case "/busy":
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	w := NewHttpClientWrapper(srv.URL, WithRetryPolicy(&DefaultRetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Minute}))
+	ctx := context.Background()
+	check := func(name string, resp *http.Response, err error, status int, want int32) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != status || calls.Load() != want {
+			t.Errorf("%s: 状态码 %d，请求 %d 次，期望 %d、%d 次", name, resp.StatusCode, calls.Load(), status, want)
+		}
+		calls.Store(0)
+	}
+
+	resp, err := w.Do(ctx, http.MethodGet, "/flaky")
+	check("GET 重试后成功", resp, err, http.StatusOK, 3)
+	resp, err = w.Do(ctx, http.MethodPost, "/down")
+	check("POST 默认不重试", resp, err, http.StatusServiceUnavailable, 1)
+	_, err = w.Do(ctx, http.MethodPost, "/down", WithHeader("Idempotency-Key", "k1"), WithBody([]byte("x"), "text/plain"))
+	var (
+		retryErr *RetryError
+		httpErr  *HTTPError
+	)
+	if !errors.As(err, &retryErr) || retryErr.Attempts != 3 || !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("POST 携带幂等键时重试，用尽后应返回带请求次数和最后一次响应的错误: %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("POST 携带幂等键时重试: 请求 %d 次，期望 3 次", calls.Load())
+	}
+	calls.Store(0)
+	resp, err = w.Do(ctx, http.MethodGet, "/busy")
+	check("Retry-After 超过最大间隔不重试", resp, err, http.StatusTooManyRequests, 1)
+
+	// 等待重试期间上下文结束
+	slow := NewHttpClientWrapper(srv.URL, WithRetryPolicy(&DefaultRetryPolicy{MaxAttempts: 3, BaseDelay: time.Minute}))
+	cctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = slow.Do(cctx, http.MethodGet, "/down")
+	if !errors.As(err, &retryErr) || retryErr.Attempts != 1 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("上下文结束应立即返回带请求次数的错误: %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("等待重试时未响应上下文结束")
+	}
+
+	// 连接被重置时重试，按请求次数报告
+	reset := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		_ = conn.Close()
+	}))
+	defer reset.Close()
+	_, err = NewHttpClientWrapper(reset.URL, WithRetry(2, time.Millisecond)).Do(ctx, http.MethodGet, "/")
+	if !errors.As(err, &retryErr) || retryErr.Attempts != 3 {
+		t.Errorf("连接被关闭应重试 2 次: %v", err)
+	}
+
+	// 连接被拒绝不重试
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	_, err = NewHttpClientWrapper(closed.URL, WithRetry(2, time.Millisecond)).Do(ctx, http.MethodGet, "/")
+	if !errors.As(err, &retryErr) || retryErr.Attempts != 1 {
+		t.Errorf("连接被拒绝不应重试: %v", err)
+	}
+}
+
+func TestIsRetriableError(t *testing.T) {
+	dial := func(err error) error {
+		return &url.Error{Op: "Get", URL: "http://example.com", Err: &net.OpError{Op: "dial", Net: "tcp", Err: err}}
+	}
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"域名不存在", dial(&net.DNSError{Err: "no such host", Name: "example.invalid", IsNotFound: true}), false},
+		{"DNS 超时", dial(&net.DNSError{Err: "i/o timeout", Name: "example.com", IsTimeout: true}), true},
+		{"DNS 临时错误", dial(&net.DNSError{Err: "server misbehaving", Name: "example.com", IsTemporary: true}), true},
+		{"连接被拒绝", dial(os.NewSyscallError("connect", syscall.ECONNREFUSED)), false},
+		{"连接被重置", dial(os.NewSyscallError("read", syscall.ECONNRESET)), true},
+		{"连接意外关闭", &url.Error{Op: "Get", URL: "http://example.com", Err: io.EOF}, true},
+		{"超时", &url.Error{Op: "Get", URL: "http://example.com", Err: context.DeadlineExceeded}, true},
+		{"调用方取消", &url.Error{Op: "Get", URL: "http://example.com", Err: context.Canceled}, false},
+	}
+	for _, c := range cases {
+		if got := isRetriableError(c.err); got != c.want {
+			t.Errorf("%s: isRetriableError 返回 %v，期望 %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestDefaultRetryPolicyBackoff(t *testing.T) {
+	p := DefaultRetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 10: 5 * time.Second} {
+		for i := 0; i < 20; i++ {
+			if got := p.backoff(attempt); got < want/2 || got > want {
+				t.Errorf("第 %d 次失败的等待时间为 %v，应在 [%v, %v] 之间", attempt, got, want/2, want)
+			}
+		}
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local))
+	b := newCircuitBreaker(CircuitBreakerConfig{FailureRatio: 0.5, MinRequests: 4, OpenDuration: time.Minute, HalfOpenProbes: 2}, clock)
+	call := func(success bool) error {
+		done, err := b.Allow()
+		if err == nil {
+			done(success)
+		}
+		return err
+	}
+
+	for _, success := range []bool{true, false, true, false} {
+		if err := call(success); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if b.State() != CircuitOpen {
+		t.Fatalf("失败比例达到阈值后应打开，实际 %s", b.State())
+	}
+	if err := call(true); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("打开时应拒绝请求: %v", err)
+	}
+
+	clock.Advance(time.Minute)
+	if b.State() != CircuitHalfOpen {
+		t.Fatalf("打开时间结束后应半开，实际 %s", b.State())
+	}
+	done1, err1 := b.Allow()
+	done2, err2 := b.Allow()
+	if _, err := b.Allow(); err1 != nil || err2 != nil || !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("半开时应只放行 2 个探测请求: %v %v %v", err1, err2, err)
+	}
+	done1(true)
+	done2(false)
+	if b.State() != CircuitOpen {
+		t.Fatalf("探测失败后应重新打开，实际 %s", b.State())
+	}
+
+	clock.Advance(time.Minute)
+	for i := 0; i < 2; i++ {
+		if err := call(true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if b.State() != CircuitClosed {
+		t.Errorf("探测全部成功后应关闭，实际 %s", b.State())
+	}
+}
+
+func TestHttpClientWrapperCircuitBreaker(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	w := NewHttpClientWrapper(srv.URL, WithRetry(2, time.Millisecond),
+		WithCircuitBreaker(CircuitBreakerConfig{MinRequests: 2, OpenDuration: time.Minute}))
+	resp, err := w.Do(context.Background(), http.MethodGet, "/")
+	var retryErr *RetryError
+	if !errors.Is(err, ErrCircuitOpen) || !errors.As(err, &retryErr) || retryErr.Attempts != 2 {
+		t.Fatalf("连续失败后应熔断并停止重试: %v %v", resp, err)
+	}
+	if _, err = w.Do(context.Background(), http.MethodGet, "/"); err != ErrCircuitOpen {
+		t.Errorf("熔断后应直接返回 ErrCircuitOpen: %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("请求次数为 %d，期望 2", calls.Load())
+	}
+	host := strings.TrimPrefix(srv.URL, "http://")
+	if state := w.CircuitStates()[host]; state != CircuitOpen {
+		t.Errorf("%s 的熔断器状态为 %s，期望 open", host, state)
+	}
+}
+
+func TestHttpClientWrapperCircuitBreakerBeforeRateLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	// 首次请求用完令牌且熔断，之后的请求应立即返回 ErrCircuitOpen 而不是等待下一个令牌
+	w := NewHttpClientWrapper(srv.URL, WithRetry(0, 0),
+		WithRateLimit(RateLimit{QPS: 0.01, MaxInFlight: 1}),
+		WithCircuitBreaker(CircuitBreakerConfig{MinRequests: 1, OpenDuration: time.Minute}))
+	resp, err := w.Do(context.Background(), http.MethodGet, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	drainBody(resp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	if _, err = w.Do(ctx, http.MethodGet, "/"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("熔断后应直接返回 ErrCircuitOpen: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("熔断时不应等待限流，耗时 %v", elapsed)
+	}
+	if n := w.LimitWaitStats().Count; n != 1 {
+		t.Errorf("熔断的请求不应占用限流，等待次数为 %d", n)
+	}
+}
+
+func TestHttpClientWrapperMiddleware(t *testing.T) {
+	srv := echoServer(t)
+	w := NewHttpClientWrapper(srv.URL, WithRetry(0, 0))
+
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next RoundTripFunc) RoundTripFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				order = append(order, name+">")
+				resp, err := next(req)
+				order = append(order, "<"+name)
+				return resp, err
+			}
+		}
+	}
+	metrics := NewHTTPMetrics()
+	w.Use(trace("a"), RequestIDMiddleware(""))
+	w.Use(LoggingMiddleware(LoggingOptions{MaxBodySize: 8}), metrics.Middleware(), trace("b"))
+
+	ctx := ContextWithRequestID(context.Background(), "req-1")
+	resp, err := w.Post("/items", map[string]string{"Authorization": "secret"}, nil, []byte(`{"name":"0123456789"}`), ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := HandleResponse[echoResult](resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(order, " "); got != "a> b> <b <a" {
+		t.Errorf("拦截器执行顺序为 %s", got)
+	}
+	if res.Header.Get("X-Request-ID") != "req-1" || res.Body != `{"name":"0123456789"}` {
+		t.Errorf("请求内容不正确: %+v", res)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"0123456789"}`))
+	req.Header.Set("Authorization", "secret")
+	if got := redactHeader(req.Header, map[string]bool{"Authorization": true}).Get("Authorization"); got != "***" {
+		t.Errorf("敏感请求头未脱敏: %s", got)
+	}
+	if got := requestBodyForLog(req, 8); got != "<stream>" {
+		t.Errorf("无法重建的请求体不应读取: %s", got)
+	}
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(`{"name":"0123456789"}`)), nil
+	}
+	if got := requestBodyForLog(req, 8); got != `{"name":...(truncated)` {
+		t.Errorf("请求体未截断: %s", got)
+	}
+	resp = &http.Response{Header: http.Header{"Content-Type": {"text/plain"}}, Body: io.NopCloser(strings.NewReader("hello world"))}
+	if got := responseBodyForLog(resp, 5); got != "hello...(truncated)" {
+		t.Errorf("响应体未截断: %s", got)
+	}
+	if body, _ := io.ReadAll(resp.Body); string(body) != "hello world" {
+		t.Errorf("记录日志后响应体不完整: %s", body)
+	}
+
+	// Do 无请求体时不设置 Content-Type，未设置请求ID时自动生成
+	if resp, err = w.Do(context.Background(), http.MethodGet, "/items"); err != nil {
+		t.Fatal(err)
+	}
+	if res, err = HandleResponse[echoResult](resp); err != nil {
+		t.Fatal(err)
+	}
+	if res.Header.Get("Content-Type") != "" || len(res.Header.Get("X-Request-ID")) != 32 {
+		t.Errorf("请求头不正确: %v", res.Header)
+	}
+
+	s := metrics.Snapshot()
+	if s.Requests != 2 || s.Statuses["2xx"] != 2 || s.Latency.Count != 2 {
+		t.Errorf("指标不正确: %+v", s)
+	}
+	var buf strings.Builder
+	if err = metrics.WritePrometheus(&buf, "http_client"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `http_client_requests_total{code="2xx"} 2`) {
+		t.Errorf("Prometheus 输出不正确:\n%s", buf.String())
+	}
+}
+
+func TestHandleResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/created":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id":1}`))
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("X-Error", "1")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"code":"not_found","message":"` + strings.Repeat("x", 5000) + `"}`))
+		}
+	}))
+	defer srv.Close()
+	w := NewHttpClientWrapper(srv.URL)
+	ctx := context.Background()
+
+	resp, err := w.Do(ctx, http.MethodPost, "/created")
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, err := HandleResponse[map[string]int](resp)
+	if err != nil || created["id"] != 1 {
+		t.Errorf("201 响应应被接受: %v %v", created, err)
+	}
+
+	if resp, err = w.Do(ctx, http.MethodDelete, "/empty"); err != nil {
+		t.Fatal(err)
+	}
+	if empty, err := HandleResponse[map[string]int](resp); err != nil || empty != nil {
+		t.Errorf("204 响应应返回零值: %v %v", empty, err)
+	}
+
+	if resp, err = w.Do(ctx, http.MethodGet, "/missing"); err != nil {
+		t.Fatal(err)
+	}
+	var apiErr struct {
+		Code string `json:"code"`
+	}
+	_, err = HandleResponse[map[string]int](resp, WithErrorBody(&apiErr))
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("应返回 *HTTPError: %v", err)
+	}
+	if httpErr.StatusCode != http.StatusNotFound || httpErr.Method != http.MethodGet || httpErr.URL != srv.URL+"/missing" ||
+		httpErr.Header.Get("X-Error") != "1" || len(httpErr.Body) != maxErrorBodySize {
+		t.Errorf("错误信息不正确: %d %s %s %d", httpErr.StatusCode, httpErr.Method, httpErr.URL, len(httpErr.Body))
+	}
+	if apiErr.Code != "not_found" {
+		t.Errorf("错误响应体解码结果为 %+v", apiErr)
+	}
+
+	if _, err = DoRequest[map[string]int](http.MethodGet, srv.URL, "/missing", nil, nil, nil); !errors.As(err, &httpErr) {
+		t.Errorf("DoRequest 应返回 *HTTPError: %v", err)
+	}
+}
+
+type codecItem struct {
+	XMLName xml.Name `json:"-" xml:"item" msgpack:"-" form:"-"`
+	ID      int      `json:"id" xml:"id" msgpack:"id" form:"id"`
+	Name    string   `json:"name" xml:"name" msgpack:"name" form:"name"`
+	Tags    []string `json:"tags" xml:"tag" msgpack:"tags" form:"tag"`
+}
+
+func TestHttpCodecs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 原样返回请求体，Content-Type 由查询参数指定
+		body, _ := io.ReadAll(r.Body)
+		if ct := r.URL.Query().Get("ct"); ct != "" {
+			w.Header().Set("Content-Type", ct)
+		} else {
+			w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		}
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+	w := NewHttpClientWrapper(srv.URL)
+	want := codecItem{ID: 1, Name: "a b", Tags: []string{"x", "y"}}
+
+	for _, contentType := range []string{"application/json", "application/xml", "application/x-www-form-urlencoded", "application/msgpack"} {
+		resp, err := w.Do(context.Background(), http.MethodPost, "/", WithEncodedBody(contentType, want))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := HandleResponse[codecItem](resp)
+		if err != nil {
+			t.Fatalf("%s: %v", contentType, err)
+		}
+		got.XMLName = xml.Name{}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s 编解码结果为 %+v", contentType, got)
+		}
+	}
+
+	// 响应的 Content-Type 不正确时通过选项指定编解码器
+	resp, err := w.Do(context.Background(), http.MethodPost, "/?ct=text/html", WithEncodedBody("application/vnd.api+json", want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := HandleResponse[codecItem](resp, WithResponseCodec("application/json"))
+	if err != nil || got.Name != "a b" {
+		t.Errorf("指定编解码器解码失败: %+v %v", got, err)
+	}
+
+	// 纯文本和原始字节响应中 string 和 []byte 直接接收原始响应体
+	if resp, err = w.Do(context.Background(), http.MethodPost, "/", WithEncodedBody("text/plain", "hello")); err != nil {
+		t.Fatal(err)
+	}
+	if text, err := HandleResponse[string](resp); err != nil || text != "hello" {
+		t.Errorf("纯文本解码结果为 %q %v", text, err)
+	}
+	if resp, err = w.Do(context.Background(), http.MethodPost, "/", WithEncodedBody("application/octet-stream", []byte(`{"id":1}`))); err != nil {
+		t.Fatal(err)
+	}
+	if raw, err := HandleResponse[[]byte](resp); err != nil || string(raw) != `{"id":1}` {
+		t.Errorf("原始字节解码结果为 %q %v", raw, err)
+	}
+	// JSON 响应中的 string 仍按 JSON 解码，除非指定编解码器
+	if resp, err = w.Do(context.Background(), http.MethodPost, "/", WithBody([]byte(`"abc"`), "application/json")); err != nil {
+		t.Fatal(err)
+	}
+	if text, err := HandleResponse[string](resp); err != nil || text != "abc" {
+		t.Errorf("JSON 字符串解码结果为 %q %v", text, err)
+	}
+	if resp, err = w.Do(context.Background(), http.MethodPost, "/", WithBody([]byte(`"abc"`), "application/json")); err != nil {
+		t.Fatal(err)
+	}
+	if text, err := HandleResponse[string](resp, WithResponseCodec("text/plain")); err != nil || text != `"abc"` {
+		t.Errorf("指定纯文本编解码器的解码结果为 %q %v", text, err)
+	}
+
+	// DoEncodedRequest 编码请求体并按响应的 Content-Type 解码
+	got, err = DoEncodedRequest[codecItem](http.MethodPost, srv.URL, "/", nil, nil, "application/xml", want, 0)
+	got.XMLName = xml.Name{}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("DoEncodedRequest 结果为 %+v %v", got, err)
+	}
+	if _, err = DoEncodedRequest[codecItem](http.MethodPost, srv.URL, "/", nil, nil, "application/protobuf", want, time.Second); err == nil {
+		t.Error("DoEncodedRequest 使用未注册的编解码器应返回错误")
+	}
+
+	if _, err = w.Do(context.Background(), http.MethodPost, "/", WithEncodedBody("application/protobuf", want)); err == nil {
+		t.Error("未注册的编解码器应返回错误")
+	}
+}
+
+func TestClientRegistry(t *testing.T) {
+	var conns atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	srv.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	r := NewClientRegistry(TransportConfig{MaxIdleConnsPerHost: 4})
+	defer r.CloseIdleConnections()
+	a := r.Get(srv.URL, "")
+	if r.Get(srv.URL, "") != a || r.Get(srv.URL, "slow", WithTimeout(time.Minute)) == a {
+		t.Fatal("客户端应按域名和名称复用")
+	}
+
+	for i := 0; i < 20; i++ {
+		res, err := DoRequest[map[string]bool](http.MethodGet, srv.URL, "/", nil, nil, nil, 5*time.Second)
+		if err != nil || !res["ok"] {
+			t.Fatalf("请求失败: %v %v", res, err)
+		}
+	}
+	if n := conns.Load(); n != 1 {
+		t.Errorf("顺序请求应复用连接，实际建立 %d 个连接", n)
+	}
+
+	// 超过上限时淘汰旧客户端
+	limited := &ClientRegistry{transport: r.transport, maxClients: 2, clients: make(map[clientKey]*HttpClientWrapper)}
+	for i := 0; i < 5; i++ {
+		limited.Get(fmt.Sprintf("http://host-%d", i), "")
+	}
+	if n := len(limited.clients); n != 2 {
+		t.Errorf("期望保留2个客户端，实际 %d 个", n)
+	}
+}
+
+func TestHttpCache(t *testing.T) {
+	var calls, revalidations atomic.Int32
+	var failing atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		switch r.URL.Path {
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store")
+		default:
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				revalidations.Add(1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local))
+	w := NewHttpClientWrapper(srv.URL, WithRetry(0, 0), WithCache(CacheOptions{StaleIfError: time.Minute}))
+	w.cache.clock = clock
+	get := func(api string) (string, map[string]bool, error) {
+		resp, err := w.Do(context.Background(), http.MethodGet, api)
+		if err != nil {
+			return "", nil, err
+		}
+		status := resp.Header.Get("X-Cache")
+		res, err := HandleResponse[map[string]bool](resp)
+		return status, res, err
+	}
+
+	for i, want := range []string{CacheMiss, CacheHit} {
+		status, res, err := get("/")
+		if err != nil || !res["ok"] || status != want {
+			t.Fatalf("第 %d 次请求: %s %v %v，期望 %s", i+1, status, res, err, want)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("未过期时不应请求上游，请求次数 %d", calls.Load())
+	}
+
+	clock.Advance(61 * time.Second)
+	if status, res, err := get("/"); err != nil || !res["ok"] || status != CacheRevalidated || revalidations.Load() != 1 {
+		t.Fatalf("过期后应通过 ETag 重新验证: %s %v %v", status, res, err)
+	}
+	if status, _, _ := get("/"); status != CacheHit {
+		t.Errorf("重新验证后应刷新有效期，实际 %s", status)
+	}
+
+	failing.Store(true)
+	clock.Advance(90 * time.Second)
+	if status, res, err := get("/"); err != nil || !res["ok"] || status != CacheStale {
+		t.Errorf("上游失败时应返回过期缓存: %s %v %v", status, res, err)
+	}
+	clock.Advance(time.Minute)
+	if _, _, err := get("/"); err == nil {
+		t.Error("超过 StaleIfError 后应返回上游错误")
+	}
+
+	failing.Store(false)
+	calls.Store(0)
+	for i := 0; i < 2; i++ {
+		if status, _, err := get("/no-store"); err != nil || status != "" {
+			t.Fatalf("no-store 响应不应缓存: %s %v", status, err)
+		}
+	}
+	if calls.Load() != 2 {
+		t.Errorf("no-store 响应每次都应请求上游，请求次数 %d", calls.Load())
+	}
+}
+
+func TestHttpCacheCredentials(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer srv.Close()
+
+	w := NewHttpClientWrapper(srv.URL, WithCache(CacheOptions{}))
+	get := func(token string) (string, string) {
+		resp, err := w.Do(context.Background(), http.MethodGet, "/me", WithBearerToken(token))
+		if err != nil {
+			t.Fatal(err)
+		}
+		status := resp.Header.Get("X-Cache")
+		body, err := HandleResponse[string](resp, WithResponseCodec("text/plain"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return status, body
+	}
+
+	for _, tc := range []struct{ token, status string }{
+		{"alice", CacheMiss},
+		{"bob", CacheMiss},
+		{"alice", CacheHit},
+		{"bob", CacheHit},
+	} {
+		status, body := get(tc.token)
+		if body != "Bearer "+tc.token || status != tc.status {
+			t.Errorf("令牌 %s 的响应为 %q（%s），期望 %s", tc.token, body, status, tc.status)
+		}
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("不同令牌应分别请求上游一次，实际请求 %d 次", n)
+	}
+}
+
+func TestHttpClientWrapperRateLimit(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	w := NewHttpClientWrapper(srv.URL, WithRateLimit(RateLimit{MaxInFlight: 4}), WithHostRateLimit(host, RateLimit{MaxInFlight: 2}))
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := w.Do(context.Background(), http.MethodGet, "/")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			drainBody(resp)
+		}()
+	}
+	wg.Wait()
+	if n := maxInFlight.Load(); n != 2 {
+		t.Errorf("最大并发数为 %d，期望 2", n)
+	}
+
+	w = NewHttpClientWrapper(srv.URL, WithPerHostRateLimit(RateLimit{QPS: 50, Burst: 1}))
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		resp, err := w.Do(context.Background(), http.MethodGet, "/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		drainBody(resp)
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("5 个请求耗时 %v，未按 QPS 限流", elapsed)
+	}
+	if s := w.LimitWaitStats(); s.Count != 5 || s.Sum <= 0 {
+		t.Errorf("等待耗时统计错误: %+v", s)
+	}
+
+	w = NewHttpClientWrapper(srv.URL, WithRateLimit(RateLimit{QPS: 0.1, Burst: 1}))
+	resp, err := w.Do(context.Background(), http.MethodGet, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	drainBody(resp)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err = w.Do(ctx, http.MethodGet, "/"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("等待令牌时上下文超时应返回超时错误: %v", err)
+	}
+}
+
+func TestHttpClientWrapperUpload(t *testing.T) {
+	content := strings.Repeat("upload-", 10000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength != -1 {
+			t.Errorf("上传应使用流式请求体，ContentLength 为 %d", r.ContentLength)
+		}
+		if r.FormValue("name") != "report" {
+			t.Errorf("表单字段错误: %q", r.FormValue("name"))
+		}
+		f, header, err := r.FormFile("file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(f)
+		if header.Filename != "report.txt" || string(data) != content {
+			t.Errorf("文件内容错误: %s %d", header.Filename, len(data))
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	var uploaded int64
+	w := NewHttpClientWrapper(srv.URL)
+	resp, err := w.Upload(context.Background(), "/upload",
+		[]MultipartFile{{Field: "file", FileName: "report.txt", Reader: strings.NewReader(content)}},
+		map[string]string{"name": "report"},
+		WithProgress(func(transferred, total int64) {
+			uploaded = transferred
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	drainBody(resp)
+	if resp.StatusCode != http.StatusCreated || uploaded <= int64(len(content)) {
+		t.Errorf("上传结果错误: %d，已上传 %d 字节", resp.StatusCode, uploaded)
+	}
+}
+
+// endlessReader 无限长的内容，读取方不主动停止时会一直读取
+type endlessReader struct{}
+
+func (endlessReader) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = 'x'
+	}
+	return len(b), nil
+}
+
+func TestHttpClientWrapperUploadRejected(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 不读取请求体直接拒绝
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	}))
+	defer srv.Close()
+	transport := NewTransport(TransportConfig{})
+	w := NewHttpClientWrapper(srv.URL, WithTransport(transport))
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		resp, err := w.Upload(context.Background(), "/upload",
+			[]MultipartFile{{Field: "file", FileName: "big.bin", Reader: endlessReader{}}}, nil,
+			WithProgress(func(transferred, total int64) {}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		drainBody(resp)
+		if resp.StatusCode != http.StatusRequestEntityTooLarge {
+			t.Fatalf("期望状态码 413，实际 %d", resp.StatusCode)
+		}
+	}
+	transport.CloseIdleConnections()
+	srv.CloseClientConnections()
+
+	// 服务端拒绝后写入协程应退出
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before+2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("上传被拒绝后协程泄漏: 上传前 %d 个，上传后 %d 个", before, runtime.NumGoroutine())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHttpClientWrapperDownload(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 10000))
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+	var interrupted atomic.Bool
+	var (
+		ranges     []string
+		rangesLock sync.Mutex
+	)
+	requestedRanges := func() []string {
+		rangesLock.Lock()
+		defer rangesLock.Unlock()
+		return append([]string(nil), ranges...)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rangesLock.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		rangesLock.Unlock()
+		if r.URL.Path == "/flaky" && interrupted.CompareAndSwap(false, true) {
+			// 只发送一半内容后断开连接
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			_, _ = w.Write(content[:len(content)/2])
+			w.(http.Flusher).Flush()
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			_ = conn.Close()
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "data.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+	w := NewHttpClientWrapper(srv.URL)
+
+	var buf bytes.Buffer
+	var progress, total int64
+	n, err := w.Download(context.Background(), "/flaky", &buf, WithChecksum(sha256.New(), checksum),
+		WithProgress(func(transferred, size int64) {
+			progress, total = transferred, size
+		}))
+	if err != nil || n != int64(len(content)) || !bytes.Equal(buf.Bytes(), content) {
+		t.Fatalf("中断后应续传完整内容: %d %v", n, err)
+	}
+	if r := requestedRanges(); len(r) != 2 || r[1] != fmt.Sprintf("bytes=%d-", len(content)/2) {
+		t.Errorf("续传请求的 Range 错误: %q", r)
+	}
+	if progress != int64(len(content)) || total != int64(len(content)) {
+		t.Errorf("进度回调错误: %d/%d", progress, total)
+	}
+
+	if _, err = w.Download(context.Background(), "/", io.Discard, WithChecksum(sha256.New(), "00")); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("校验和不一致时应返回 ErrChecksumMismatch: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "data.txt")
+	if err = os.WriteFile(path, content[:3000], 0644); err != nil {
+		t.Fatal(err)
+	}
+	rangesLock.Lock()
+	ranges = nil
+	rangesLock.Unlock()
+	if n, err = w.DownloadFile(context.Background(), "/", path, WithChecksum(sha256.New(), checksum)); err != nil || n != int64(len(content)-3000) {
+		t.Fatalf("应从已有文件末尾续传: %d %v", n, err)
+	}
+	if data, _ := os.ReadFile(path); !bytes.Equal(data, content) || requestedRanges()[0] != "bytes=3000-" {
+		t.Errorf("续传后文件内容错误，Range: %q", requestedRanges())
+	}
+	if n, err = w.DownloadFile(context.Background(), "/", path, WithChecksum(sha256.New(), checksum)); err != nil || n != 0 {
+		t.Errorf("已下载完成的文件不应重复下载: %d %v", n, err)
+	}
+}
+
+func TestClientCredentials(t *testing.T) {
+	var issued, apiCalls atomic.Int32
+	var valid atomic.Value
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != "client" || secret != "secret" || r.FormValue("grant_type") != "client_credentials" || r.FormValue("scope") != "read write" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+		token := fmt.Sprintf("t%d", issued.Add(1))
+		valid.Store(token)
+		_, _ = fmt.Fprintf(w, `{"access_token":%q,"token_type":"bearer","expires_in":3600}`, token)
+	}))
+	defer tokenSrv.Close()
+	apiSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiCalls.Add(1)
+		if r.Header.Get("Authorization") != "Bearer "+valid.Load().(string) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	}))
+	defer apiSrv.Close()
+
+	clock := NewFakeClock(time.Now())
+	cc := newClientCredentials(ClientCredentialsConfig{
+		TokenURL:     tokenSrv.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Scopes:       []string{"read", "write"},
+	}, clock)
+	w := NewHttpClientWrapper(apiSrv.URL, WithAuth(cc))
+	call := func() error {
+		resp, err := w.Do(context.Background(), http.MethodPost, "/", WithBody([]byte("ping"), "text/plain"))
+		if err != nil {
+			return err
+		}
+		if body, err := HandleResponse[string](resp); err != nil || body != "ping" {
+			return fmt.Errorf("响应错误: %q %v", body, err)
+		}
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := call(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := issued.Load(); n != 1 {
+		t.Fatalf("并发请求应只获取一次令牌，实际 %d 次", n)
+	}
+
+	// 服务端吊销令牌后，请求返回 401，刷新令牌后重试一次
+	valid.Store("revoked")
+	apiCalls.Store(0)
+	if err := call(); err != nil || issued.Load() != 2 || apiCalls.Load() != 2 {
+		t.Fatalf("401 后应刷新令牌并重试一次: %v，令牌 %d 次，请求 %d 次", err, issued.Load(), apiCalls.Load())
+	}
+
+	// 即将过期时返回当前令牌并在后台刷新
+	clock.Advance(time.Hour - 30*time.Second)
+	if token, err := cc.Token(context.Background()); err != nil || token.AccessToken != "t2" {
+		t.Fatalf("即将过期时应返回当前令牌: %v %v", token, err)
+	}
+	var token *Token
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if token, _ = cc.Token(context.Background()); token.AccessToken != "t2" {
+			break
+		}
+	}
+	if token.AccessToken != "t3" || issued.Load() != 3 {
+		t.Errorf("后台刷新后应使用新令牌: %v，令牌 %d 次", token, issued.Load())
+	}
+
+	clock.Advance(2 * time.Hour)
+	if token, err := cc.Token(context.Background()); err != nil || token.AccessToken != "t4" {
+		t.Errorf("过期后应获取新令牌: %v %v", token, err)
+	}
+}
+
+func TestLRUCacheStore(t *testing.T) {
+	s := NewLRUCacheStore(10)
+	s.Set("a", &CacheEntry{Body: []byte("aaaa")})
+	s.Set("b", &CacheEntry{Body: []byte("bbbb")})
+	s.Get("a")
+	s.Set("c", &CacheEntry{Body: []byte("cccc")})
+	if _, ok := s.Get("b"); ok {
+		t.Error("超出大小上限时应淘汰最久未使用的条目")
+	}
+	if _, ok := s.Get("a"); !ok {
+		t.Error("最近使用的条目不应被淘汰")
+	}
+	s.Set("d", &CacheEntry{Body: []byte("too large body")})
+	if _, ok := s.Get("d"); ok || s.Len() != 2 {
+		t.Errorf("超过大小上限的条目不应缓存，当前条目数 %d", s.Len())
+	}
+}
+
+func benchmarkServer(b *testing.B) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	b.Cleanup(srv.Close)
+	return srv
+}
+
+// BenchmarkDoRequest 通过注册表复用客户端和连接池
+func BenchmarkDoRequest(b *testing.B) {
+	srv := benchmarkServer(b)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := DoRequest[map[string]bool](http.MethodGet, srv.URL, "/", nil, nil, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkDoRequestNewClient 与注册表引入前的 DoRequest 相同，每次请求创建新的客户端，使用 http.DefaultTransport
+func BenchmarkDoRequestNewClient(b *testing.B) {
+	srv := benchmarkServer(b)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			resp, err := NewHttpClientWrapper(srv.URL, WithTransport(http.DefaultTransport)).request(http.MethodGet, "/", nil, nil, nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err = HandleResponse[map[string]bool](resp); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,350 @@
+package utils
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrChecksumMismatch 下载内容的校验和与期望值不一致
+var ErrChecksumMismatch = errors.New("校验和不一致")
+
+// 下载中断后最多续传的次数
+const maxDownloadResumes = 3
+
+// ProgressFunc 传输进度回调，transferred 为已传输的字节数，total 未知时为 -1
+type ProgressFunc func(transferred, total int64)
+
+// WithBodyReader 以 r 作为流式请求体，请求体只能读取一次，因此请求失败时不会重试
+func WithBodyReader(r io.Reader, contentType string) RequestOption {
+	return func(c *requestConfig) {
+		c.bodyReader = r
+		c.setBody(nil, contentType)
+	}
+}
+
+// WithProgress 设置 Upload、Download 的进度回调
+func WithProgress(fn ProgressFunc) RequestOption {
+	return func(c *requestConfig) {
+		c.progress = fn
+	}
+}
+
+// WithChecksum 下载完成后校验内容的哈希值，expected 为十六进制编码，如 WithChecksum(sha256.New(), "9f86d0...")
+func WithChecksum(h hash.Hash, expected string) RequestOption {
+	return func(c *requestConfig) {
+		c.checksum = h
+		c.expectedSum = strings.ToLower(expected)
+	}
+}
+
+// WithRangeOffset Download 从第 offset 字节开始下载，用于在已下载的部分内容之后续传
+func WithRangeOffset(offset int64) RequestOption {
+	return func(c *requestConfig) {
+		c.rangeOffset = offset
+	}
+}
+
+type transferKey struct{}
+
+// isTransfer 判断是否为 Upload、Download 发出的请求，这些请求不受客户端超时限制，也不经过响应缓存
+func isTransfer(ctx context.Context) bool {
+	return ctx.Value(transferKey{}) != nil
+}
+
+// Upload 以 multipart/form-data 流式上传表单字段和文件，文件内容边读取边发送，不会全部读入内存。
+// 上传不受客户端超时限制，由 ctx 控制；请求体只能读取一次，失败时不会重试。进度回调的 total 为 -1。
+func (w *HttpClientWrapper) Upload(ctx context.Context, api string, files []MultipartFile, fields map[string]string, opts ...RequestOption) (*http.Response, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(writeMultipart(mw, fields, files))
+	}()
+
+	cfg := newRequestConfig(opts)
+	var body io.Reader = pr
+	if cfg.progress != nil {
+		body = &progressReader{r: pr, total: -1, progress: cfg.progress}
+	}
+	opts = append(opts[:len(opts):len(opts)], WithBodyReader(body, mw.FormDataContentType()))
+	resp, err := w.Do(context.WithValue(ctx, transferKey{}, true), http.MethodPost, api, opts...)
+	if err != nil {
+		// 请求未读取请求体时结束写入协程
+		pr.CloseWithError(err)
+	}
+	return resp, err
+}
+
+func writeMultipart(mw *multipart.Writer, fields map[string]string, files []MultipartFile) error {
+	for k, v := range fields {
+		if err := mw.WriteField(k, v); err != nil {
+			return fmt.Errorf("写入表单字段失败: %v", err)
+		}
+	}
+	for _, f := range files {
+		part, err := mw.CreateFormFile(f.Field, f.FileName)
+		if err != nil {
+			return fmt.Errorf("创建文件表单失败: %v", err)
+		}
+		if _, err = io.Copy(part, f.Reader); err != nil {
+			return fmt.Errorf("读取文件 %s 失败: %v", f.FileName, err)
+		}
+	}
+	return mw.Close()
+}
+
+// Download 以 GET 请求流式下载到 dst，返回本次写入的字节数。
+// 读取响应体中断且服务端支持 Range 时，从已下载的位置续传，最多续传 3 次；
+// 续传时资源已变化（服务端返回 200）且 dst 为 *os.File 等可截断的文件时从头下载，否则返回错误。
+// 设置 WithChecksum 时下载完成后校验哈希值，不一致时返回 ErrChecksumMismatch。
+// 下载不受客户端超时限制，由 ctx 控制。
+func (w *HttpClientWrapper) Download(ctx context.Context, api string, dst io.Writer, opts ...RequestOption) (int64, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx = context.WithValue(ctx, transferKey{}, true)
+	cfg := newRequestConfig(opts)
+
+	d := &download{dst: dst, cfg: cfg, offset: cfg.rangeOffset, total: -1}
+	for resumes := 0; ; resumes++ {
+		err := d.fetch(ctx, w, api, opts)
+		if err == nil {
+			break
+		}
+		var readErr *downloadReadError
+		if !errors.As(err, &readErr) || !d.resumable || ctx.Err() != nil || resumes >= maxDownloadResumes {
+			return d.written, err
+		}
+		GetLogger().Warnf("下载 %s 在 %d 字节处中断，尝试续传: %v", api, d.offset+d.written, readErr.err)
+	}
+
+	if cfg.checksum != nil {
+		if actual := hex.EncodeToString(cfg.checksum.Sum(nil)); actual != cfg.expectedSum {
+			return d.written, fmt.Errorf("%w: 期望 %s，实际 %s", ErrChecksumMismatch, cfg.expectedSum, actual)
+		}
+	}
+	return d.written, nil
+}
+
+// DownloadFile 下载到文件 path，文件已存在时从文件末尾续传。
+// 设置 WithChecksum 时已有内容同样参与校验，校验失败时删除文件。
+func (w *HttpClientWrapper) DownloadFile(ctx context.Context, api, path string, opts ...RequestOption) (int64, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("打开文件失败: %v", err)
+	}
+
+	// 已有内容计入校验和，读取后文件位置位于末尾
+	var offset int64
+	if cfg := newRequestConfig(opts); cfg.checksum != nil {
+		offset, err = io.Copy(cfg.checksum, f)
+	} else {
+		offset, err = f.Seek(0, io.SeekEnd)
+	}
+	if err != nil {
+		_ = f.Close()
+		return 0, fmt.Errorf("读取已下载内容失败: %v", err)
+	}
+
+	n, err := w.Download(ctx, api, f, append(opts[:len(opts):len(opts)], WithRangeOffset(offset))...)
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("关闭文件失败: %v", closeErr)
+	}
+	if errors.Is(err, ErrChecksumMismatch) {
+		_ = os.Remove(path)
+	}
+	return n, err
+}
+
+// download 一次下载的状态
+type download struct {
+	dst       io.Writer
+	cfg       *requestConfig
+	offset    int64  // 起始位置
+	written   int64  // 本次已写入的字节数
+	total     int64  // 资源总大小，未知时为 -1
+	validator string // 用于 If-Range 的 ETag 或 Last-Modified
+	resumable bool   // 服务端是否支持 Range
+}
+
+// downloadReadError 读取响应体失败，可以续传
+type downloadReadError struct {
+	err error
+}
+
+func (e *downloadReadError) Error() string {
+	return fmt.Sprintf("读取响应体失败: %v", e.err)
+}
+
+func (e *downloadReadError) Unwrap() error {
+	return e.err
+}
+
+// fetch 从当前位置发送一次请求并写入 dst
+func (d *download) fetch(ctx context.Context, w *HttpClientWrapper, api string, opts []RequestOption) error {
+	start := d.offset + d.written
+	if start > 0 {
+		opts = append(opts[:len(opts):len(opts)], WithHeader("Range", fmt.Sprintf("bytes=%d-", start)))
+		if d.validator != "" {
+			opts = append(opts, WithHeader("If-Range", d.validator))
+		}
+	}
+	resp, err := w.Do(ctx, http.MethodGet, api, opts...)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		first, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || first != start {
+			return fmt.Errorf("响应的 Content-Range 与请求不一致: %s", resp.Header.Get("Content-Range"))
+		}
+		d.total = total
+		d.resumable = true
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && start > 0:
+		// 请求的位置已到达末尾，即此前已下载完成
+		if _, total, ok := parseContentRange(resp.Header.Get("Content-Range")); ok && total == start {
+			return nil
+		}
+		return newHTTPError(resp, readErrorBody(resp))
+	case resp.StatusCode == http.StatusOK:
+		if start > 0 {
+			if err = d.restart(); err != nil {
+				return err
+			}
+		}
+		d.total = resp.ContentLength
+		d.resumable = resp.Header.Get("Accept-Ranges") == "bytes"
+	default:
+		return newHTTPError(resp, readErrorBody(resp))
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		d.validator = etag
+	} else {
+		d.validator = resp.Header.Get("Last-Modified")
+	}
+
+	return d.copy(resp.Body)
+}
+
+// restart 服务端忽略 Range 返回完整内容时，清空已写入的内容从头下载
+func (d *download) restart() error {
+	f, ok := d.dst.(interface {
+		io.Seeker
+		Truncate(size int64) error
+	})
+	if !ok {
+		return errors.New("服务端不支持断点续传，且写入目标无法清空")
+	}
+	if err := f.Truncate(0); err != nil {
+		return fmt.Errorf("清空文件失败: %v", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("清空文件失败: %v", err)
+	}
+	if d.cfg.checksum != nil {
+		d.cfg.checksum.Reset()
+	}
+	d.offset, d.written = 0, 0
+	return nil
+}
+
+// copy 将响应体写入 dst，同时计算校验和并回调进度，读取失败时返回 *downloadReadError
+func (d *download) copy(body io.Reader) error {
+	buf := make([]byte, 32*1024)
+	for {
+		n, readErr := body.Read(buf)
+		if n > 0 {
+			if _, err := d.dst.Write(buf[:n]); err != nil {
+				return fmt.Errorf("写入下载内容失败: %v", err)
+			}
+			if d.cfg.checksum != nil {
+				d.cfg.checksum.Write(buf[:n])
+			}
+			d.written += int64(n)
+			if d.cfg.progress != nil {
+				d.cfg.progress(d.offset+d.written, d.total)
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return &downloadReadError{err: readErr}
+		}
+	}
+}
+
+// parseContentRange 解析 "bytes 100-199/1000" 或 "bytes */1000"，返回起始位置和总大小，总大小未知时为 -1
+func parseContentRange(value string) (first, total int64, ok bool) {
+	value, found := strings.CutPrefix(value, "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	rng, size, found := strings.Cut(value, "/")
+	if !found {
+		return 0, 0, false
+	}
+	total = -1
+	if size != "*" {
+		var err error
+		if total, err = strconv.ParseInt(size, 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	if rng == "*" {
+		return 0, total, true
+	}
+	firstStr, _, found := strings.Cut(rng, "-")
+	if !found {
+		return 0, 0, false
+	}
+	first, err := strconv.ParseInt(firstStr, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return first, total, true
+}
+
+func readErrorBody(resp *http.Response) []byte {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	return body
+}
+
+// progressReader 读取时回调进度
+type progressReader struct {
+	r        io.Reader
+	read     int64
+	total    int64
+	progress ProgressFunc
+}
+
+// Close 关闭底层的请求体，传输层结束请求时通过它通知写入方，如 Upload 的写入协程
+func (p *progressReader) Close() error {
+	if c, ok := p.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.read += int64(n)
+		p.progress(p.read, p.total)
+	}
+	return n, err
+}
@@ -11,6 +11,7 @@ type JwtUtil struct {
 	signKey     []byte
 	expireTime  time.Duration
 	refreshTime time.Duration
+	clock       Clock
 }
 
 type JwtOption func(*JwtUtil)
@@ -33,11 +34,19 @@ func WithRefreshTime(d time.Duration) JwtOption {
 	}
 }
 
+// WithJwtClock 设置签发和校验过期时间使用的时钟，测试中可传入 FakeClock
+func WithJwtClock(clock Clock) JwtOption {
+	return func(j *JwtUtil) {
+		j.clock = clock
+	}
+}
+
 func NewJwtUtil(opts ...JwtOption) (*JwtUtil, error) {
 	ju := &JwtUtil{
 		signKey:     []byte("default_key"),
 		expireTime:  7 * 24 * time.Hour,
 		refreshTime: 24 * time.Hour,
+		clock:       SystemClock(),
 	}
 
 	for _, opt := range opts {
@@ -58,7 +67,7 @@ func NewJwtUtil(opts ...JwtOption) (*JwtUtil, error) {
 }
 
 func (j *JwtUtil) Generate(info any) (string, error) {
-	claims := jwt.MapClaims{"exp": float64(time.Now().Add(j.expireTime).Unix()), "info": info}
+	claims := jwt.MapClaims{"exp": float64(j.clock.Now().Add(j.expireTime).Unix()), "info": info}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(j.signKey)
 }
@@ -69,7 +78,7 @@ func (j *JwtUtil) Parse(tokenStr string) (info any, err error) {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return j.signKey, nil
-	})
+	}, jwt.WithTimeFunc(j.clock.Now))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %v", err)
 	}
@@ -26,3 +26,24 @@ func TestGetJwtUtil(t *testing.T) {
 	}
 	println(info.(string))
 }
+
+func TestJwtUtilExpire(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	instance, err := NewJwtUtil(WithExpireTime(time.Hour), WithRefreshTime(time.Minute), WithJwtClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenStr, err := instance.Generate("张三")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(59 * time.Minute)
+	if _, err = instance.Parse(tokenStr); err != nil {
+		t.Errorf("token 未过期却校验失败: %v", err)
+	}
+	clock.Advance(2 * time.Minute)
+	if _, err = instance.Parse(tokenStr); err == nil {
+		t.Error("token 已过期却校验通过")
+	}
+}
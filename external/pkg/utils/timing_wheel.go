@@ -1,9 +1,13 @@
 package utils
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/panjf2000/ants/v2"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -12,18 +16,46 @@ type timingWheelStatus int8
 const (
 	ready timingWheelStatus = iota
 	running
+	stopping
 	stopped
 )
 
 // TaskHandler 任务处理函数
 type TaskHandler func(data any, tc TaskContext)
 
+// ErrTaskHandler 可返回错误的任务处理函数，返回错误时按任务的重试策略重试
+type ErrTaskHandler func(data any, tc TaskContext) error
+
 // SubmitErrorHandler 任务提交错误处理函数
 type SubmitErrorHandler func(data any, err error)
 
+var (
+	// ErrTaskExists 任务ID已存在
+	ErrTaskExists = errors.New("任务ID已存在")
+	// ErrTaskNotFound 任务不存在或已触发
+	ErrTaskNotFound = errors.New("任务不存在或已执行完毕")
+)
+
 // TimingWheelOption 时间轮配置选项
 type TimingWheelOption func(*TimingWheel)
 
+// TaskOption 任务配置选项
+type TaskOption func(*task)
+
+// WithTaskID 设置任务ID，用于 CancelTask 和 UpdateTask，ID 已存在时添加失败
+func WithTaskID(id string) TaskOption {
+	return func(t *task) {
+		t.id = id
+	}
+}
+
+// WithPriority 设置任务优先级，数值越大越先分发，默认0
+func WithPriority(priority int) TaskOption {
+	return func(t *task) {
+		t.priority = priority
+	}
+}
+
 // WithPoolSize 设置协程池大小
 func WithPoolSize(size int) TimingWheelOption {
 	return func(tw *TimingWheel) {
@@ -45,7 +77,7 @@ func WithPoolOptions(options ...ants.Option) TimingWheelOption {
 	}
 }
 
-// WithMaxTasksPerSlot 设置每个槽位最大任务数
+// WithMaxTasksPerSlot 设置每个槽位单次tick最多分发的任务数，超出部分按优先级顺延到下一槽位
 func WithMaxTasksPerSlot(max int) TimingWheelOption {
 	return func(tw *TimingWheel) {
 		tw.maxTasksPerSlot = max
@@ -59,9 +91,16 @@ func WithMetrics(enable bool) TimingWheelOption {
 	}
 }
 
+// WithClock 设置时间轮使用的时钟，测试中可传入 FakeClock
+func WithClock(clock Clock) TimingWheelOption {
+	return func(tw *TimingWheel) {
+		tw.clock = clock
+	}
+}
+
 // NewTimingWheel 创建一个新的时间轮，不使用协程池
-func NewTimingWheel(intervalSeconds uint32, scale uint64) *TimingWheel {
-	return newTimingWheel(intervalSeconds, scale, false, nil)
+func NewTimingWheel(intervalSeconds uint32, scale uint64, opts ...TimingWheelOption) *TimingWheel {
+	return newTimingWheel(intervalSeconds, scale, false, opts)
 }
 
 // NewTimingWheelWithPool 创建一个新的时间轮，使用协程池
@@ -80,38 +119,73 @@ func newTimingWheel(intervalSeconds uint32, scale uint64, usePool bool, opts []T
 		nodes:           make([]*node, scale),
 		status:          ready,
 		usePool:         usePool,
-		taskQueue:       make(chan *task, 1000),
+		queueSize:       1000,
 		maxTasksPerSlot: 10000, // 默认每个槽位最大任务数
+		failureHistory:  defaultFailureHistory,
+		clock:           SystemClock(),
+		tasks:           make(map[string]*task),
+		runningTasks:    make(map[string]*task),
 	}
 
-	if tw.enableMetrics {
-		tw.metrics = &Metrics{}
+	for _, opt := range opts {
+		opt(tw)
 	}
 
-	if usePool && opts != nil {
-		for _, opt := range opts {
-			opt(tw)
-		}
+	// 选项应用之后再初始化指标，否则 WithMetrics 不生效
+	if tw.enableMetrics {
+		tw.metrics = newMetrics()
 	}
+	tw.failures = newFailureLog(tw.failureHistory)
 
 	tw.initNodes()
 	return tw
 }
 
 type task struct {
-	round   uint64
-	data    any
-	handler TaskHandler
-	tw      *TimingWheel
+	id        string
+	round     uint64
+	data      any
+	handler   ErrTaskHandler
+	tw        *TimingWheel
+	due       time.Time        // 预期触发时间
+	retry     *TaskRetryPolicy // 重试策略，nil 表示不重试
+	attempt   int              // 已执行次数
+	priority  int
+	keyPolicy *KeyPolicy    // 按业务键去重的策略，nil 表示ID冲突时报错
+	timeout   time.Duration // 单次执行超时时间，0 表示不限制
+	labels    map[string]string
+	cancelled bool               // 由 tasksLock 保护
+	cancelRun context.CancelFunc // 执行期间取消任务上下文，由 tasksLock 保护
 }
 
-func (t *task) handle() {
+// clone 复制任务的定义和执行次数，用于替换槽位中的任务后重新调度，须持有 tasksLock。
+// round 由调度循环在不持有 tasksLock 时修改，因此逐个字段复制而不是复制整个结构体
+func (t *task) clone() *task {
+	return &task{
+		id:        t.id,
+		data:      t.data,
+		handler:   t.handler,
+		tw:        t.tw,
+		due:       t.due,
+		retry:     t.retry,
+		attempt:   t.attempt,
+		priority:  t.priority,
+		keyPolicy: t.keyPolicy,
+		timeout:   t.timeout,
+		labels:    t.labels,
+	}
+}
+
+// handle 执行任务处理函数，panic 会被转换为错误返回
+func (t *task) handle(tc TaskContext) (panicked bool, err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			GetLogger().Errorf("任务处理发生panic: %v", r)
+			panicked = true
+			err = fmt.Errorf("任务处理发生panic: %v", r)
+			GetLogger().Error(err)
 		}
 	}()
-	t.handler(t.data, t.tw)
+	return false, t.handler(t.data, tc)
 }
 
 type node struct {
@@ -127,10 +201,9 @@ type TimingWheel struct {
 	scale            uint64
 	nodes            []*node
 	current          uint64
-	stop             chan struct{}
+	rs               *runState
 	status           timingWheelStatus
 	lock             sync.Mutex
-	pool             *ants.Pool
 	usePool          bool
 	poolSize         int
 	poolOptions      []ants.Option
@@ -138,7 +211,77 @@ type TimingWheel struct {
 	maxTasksPerSlot  int
 	enableMetrics    bool
 	metrics          *Metrics
-	taskQueue        chan *task // 用于任务缓冲
+	queueSize        int
+	workers          int
+	overflow         OverflowStrategy
+	deadLetter       DeadLetterHandler
+	deadLetters      chan DeadLetter
+	pendingHandler   PendingTaskHandler
+	failureHistory   int
+	failures         *failureLog
+	clock            Clock
+	tasks            map[string]*task // 未触发的任务，由 tasksLock 保护
+	runningTasks     map[string]*task // 已分发（排队或执行中）的任务，由 tasksLock 保护
+	tasksLock        sync.Mutex
+	taskSeq          atomic.Uint64
+}
+
+// runState 单次运行期间的状态，每次 Start 重新创建以支持停止后重启
+type runState struct {
+	ctx       context.Context // 任务上下文的父上下文，时间轮停止时取消
+	cancel    context.CancelFunc
+	stop      chan struct{}
+	done      chan struct{} // run 循环退出后关闭
+	pool      *ants.Pool
+	taskQueue chan *task // 到期任务的分发队列，run 循环退出后关闭
+	running   taskGroup  // 已入队和正在执行的任务
+}
+
+// taskGroup 统计已入队和正在执行的任务。与 sync.WaitGroup 不同，允许在 Wait 期间从任意协程 Add，
+// FireNow 可能在手动时钟的 run 循环等待本次触发的任务时分发任务
+type taskGroup struct {
+	mutex sync.Mutex
+	cond  *sync.Cond
+	n     int
+}
+
+func (g *taskGroup) Add(delta int) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.n += delta
+	if g.n < 0 {
+		panic("taskGroup: negative counter")
+	}
+	if g.n == 0 && g.cond != nil {
+		g.cond.Broadcast()
+	}
+}
+
+func (g *taskGroup) Done() {
+	g.Add(-1)
+}
+
+// Wait 阻塞直到计数为0
+func (g *taskGroup) Wait() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.cond == nil {
+		g.cond = sync.NewCond(&g.mutex)
+	}
+	for g.n > 0 {
+		g.cond.Wait()
+	}
+}
+
+// release 等待运行中的任务结束后释放协程池
+func (rs *runState) release() {
+	<-rs.done
+	rs.running.Wait()
+	if rs.pool != nil {
+		rs.pool.Release()
+	}
 }
 
 func (tw *TimingWheel) initNodes() {
@@ -155,80 +298,136 @@ func (tw *TimingWheel) initNodes() {
 	tail.next = head
 }
 
-// Start 启动时间轮
+// Start 启动时间轮，停止后可再次启动，Stop 保留的任务会继续调度
 func (tw *TimingWheel) Start() error {
 	tw.lock.Lock()
 	defer tw.lock.Unlock()
 
-	if tw.status == running {
+	switch tw.status {
+	case running:
 		return fmt.Errorf("时间轮已经在运行")
+	case stopping:
+		return fmt.Errorf("时间轮正在停止")
 	}
 
+	rs := &runState{
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+		taskQueue: make(chan *task, tw.queueSize),
+	}
 	if tw.usePool {
 		if tw.poolSize == 0 {
 			tw.poolSize = 1000 // 默认池大小
 		}
 		var err error
-		tw.pool, err = ants.NewPool(tw.poolSize, tw.poolOptions...)
+		rs.pool, err = ants.NewPool(tw.poolSize, tw.poolOptions...)
 		if err != nil {
 			return fmt.Errorf("创建协程池失败: %v", err)
 		}
 	}
 
+	rs.ctx, rs.cancel = context.WithCancel(context.Background())
+
+	workers := tw.workers
+	if workers <= 0 {
+		workers = defaultWorkers
+		if rs.pool != nil {
+			// 使用协程池时并发度由池大小控制，单个分发协程即可
+			workers = 1
+		}
+	}
+	for i := 0; i < workers; i++ {
+		go tw.worker(rs)
+	}
+
 	tw.status = running
-	tw.stop = make(chan struct{})
-	go tw.run()
+	tw.rs = rs
+	// 在 Start 中创建 Ticker，保证返回后推进手动时钟即可触发
+	go tw.run(rs, tw.clock.NewTicker(time.Duration(tw.interval)*time.Second))
 	return nil
 }
 
-func (tw *TimingWheel) run() {
-	ticker := time.NewTicker(time.Duration(tw.interval) * time.Second)
+func (tw *TimingWheel) run(rs *runState, ticker Ticker) {
+	defer close(rs.done)
+	// 只有 run 循环和 FireNow（持有 tw.lock 且时间轮运行中）向队列写入，退出时关闭队列，分发协程处理完剩余任务后退出
+	defer close(rs.taskQueue)
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
-			tw.tick()
-		case <-tw.stop:
-			if tw.pool != nil {
-				tw.pool.Release()
+		case <-ticker.C():
+			tw.tick(rs)
+			if st, ok := ticker.(syncTicker); ok {
+				// 手动时钟需等待本次触发的任务全部执行完毕
+				rs.running.Wait()
+				st.ack()
 			}
+		case <-rs.stop:
 			GetLogger().Info("时间轮已停止!")
 			return
 		}
 	}
 }
 
-func (tw *TimingWheel) tick() {
+func (tw *TimingWheel) tick(rs *runState) {
+	tw.lock.Lock()
 	currentNode := tw.nodes[tw.current]
+	tw.current = (tw.current + 1) % tw.scale
+	tw.lock.Unlock()
+
 	currentNode.lock.Lock()
 	tasks := currentNode.tasks
 	currentNode.tasks = nil
 	currentNode.lock.Unlock()
 
 	if len(tasks) == 0 {
-		tw.current = (tw.current + 1) % tw.scale
 		return
 	}
 
-	// 使用任务队列进行缓冲
-	go func() {
-		for _, task := range tasks {
-			if task.round > 0 {
-				task.round--
-				tw.reinsertTask(task)
-				continue
-			}
-			tw.processTask(task)
+	due := make([]*task, 0, len(tasks))
+	for _, task := range tasks {
+		if task.round > 0 {
+			// 未到触发轮次，放回原槽位等待下一圈
+			task.round--
+			tw.reinsertTask(currentNode, task)
+			continue
 		}
-	}()
+		if !tw.isCancelled(task) {
+			due = append(due, task)
+		}
+	}
 
-	tw.current = (tw.current + 1) % tw.scale
+	// 按优先级分发，超出单槽位上限的低优先级任务顺延到下一槽位
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].priority > due[j].priority
+	})
+	if tw.maxTasksPerSlot > 0 && len(due) > tw.maxTasksPerSlot {
+		GetLogger().Warnf("槽位到期任务数 %d 超过限制 %d，低优先级任务顺延", len(due), tw.maxTasksPerSlot)
+		for _, t := range due[tw.maxTasksPerSlot:] {
+			tw.reinsertTask(currentNode.next, t)
+		}
+		due = due[:tw.maxTasksPerSlot]
+	}
+
+	// 按顺序同步入队，保证同一槽位的任务按优先级和添加顺序出队
+	for _, task := range due {
+		if tw.takeTask(task) {
+			tw.enqueue(rs, currentNode.next, task)
+		}
+	}
 }
 
 // AddTask 添加定时任务
 func (tw *TimingWheel) AddTask(data any, handler TaskHandler, duration time.Duration) error {
-	if tw.status != running {
+	return tw.AddErrTask(data, func(data any, tc TaskContext) error {
+		handler(data, tc)
+		return nil
+	}, duration)
+}
+
+// AddErrTask 添加可返回错误的定时任务，可通过 WithTaskRetry 设置重试策略
+func (tw *TimingWheel) AddErrTask(data any, handler ErrTaskHandler, duration time.Duration, opts ...TaskOption) error {
+	if !tw.isRunning() {
 		return fmt.Errorf("时间轮未启动")
 	}
 
@@ -236,114 +435,232 @@ func (tw *TimingWheel) AddTask(data any, handler TaskHandler, duration time.Dura
 		return fmt.Errorf("duration不能为负数")
 	}
 
+	t := &task{
+		data:    data,
+		tw:      tw,
+		handler: handler,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	tw.tasksLock.Lock()
+	defer tw.tasksLock.Unlock()
+
+	if t.id == "" {
+		t.id = fmt.Sprintf("task-%d", tw.taskSeq.Add(1))
+	} else if existing, ok := tw.tasks[t.id]; ok {
+		if t.keyPolicy == nil {
+			return ErrTaskExists
+		}
+		switch *t.keyPolicy {
+		case KeyIgnore:
+			return nil
+		case KeyKeepEarliest:
+			if !tw.clock.Now().Add(duration).Before(existing.due) {
+				return nil
+			}
+		}
+		existing.cancelled = true
+		tw.metrics.taskCancelled()
+	}
+
+	tw.tasks[t.id] = t
+	tw.schedule(t, duration)
+	tw.metrics.taskAdded()
+	return nil
+}
+
+// schedule 将任务放入 duration 之后触发的槽位
+func (tw *TimingWheel) schedule(t *task, duration time.Duration) {
 	afterSeconds := uint64(duration.Seconds())
 	if afterSeconds >= uint64(tw.interval) {
 		afterSeconds -= uint64(tw.interval)
 	}
 
 	index := (afterSeconds / uint64(tw.interval)) % tw.scale
-	round := (afterSeconds / uint64(tw.interval)) / tw.scale
-
-	t := &task{
-		round:   round,
-		data:    data,
-		tw:      tw,
-		handler: handler,
-	}
+	t.round = (afterSeconds / uint64(tw.interval)) / tw.scale
+	t.due = tw.clock.Now().Add(duration)
 
+	tw.lock.Lock()
 	node := tw.nodes[(tw.current+index)%tw.scale]
+	tw.lock.Unlock()
+
 	node.lock.Lock()
 	node.tasks = append(node.tasks, t)
 	node.lock.Unlock()
+}
 
-	return nil
+func (tw *TimingWheel) isRunning() bool {
+	tw.lock.Lock()
+	defer tw.lock.Unlock()
+	return tw.status == running
+}
+
+func (tw *TimingWheel) acceptsRetry() bool {
+	tw.lock.Lock()
+	defer tw.lock.Unlock()
+	return tw.status == running || tw.status == stopping
 }
 
-// Stop 停止时间轮
+// Stop 停止时间轮，不等待运行中的任务并取消其上下文，未触发的任务保留在时间轮中，重新 Start 后继续调度
 func (tw *TimingWheel) Stop() {
 	tw.lock.Lock()
 	defer tw.lock.Unlock()
 
 	if tw.status == running {
-		close(tw.stop)
+		close(tw.rs.stop)
+		tw.rs.cancel()
 		tw.status = stopped
+		go tw.rs.release()
 	}
 }
 
-// TaskContext 任务上下文接口
-type TaskContext interface {
-	AddTask(data any, handler TaskHandler, duration time.Duration) error
-}
-
-// GetMetrics 获取指标数据
+// GetMetrics 获取指标数据，未启用指标时返回nil。
+// 返回值的 TotalTasks 等字段为调用时的计数，Snapshot 返回实时数据
 func (tw *TimingWheel) GetMetrics() *Metrics {
-	if !tw.enableMetrics {
-		return nil
-	}
-	return tw.metrics
+	return tw.metrics.withLegacyFields()
 }
 
-// CancelTask 取消任务
+// CancelTask 取消任务。未触发的任务在所在槽位到期时被丢弃，排队中的任务不再执行；
+// 正在执行的任务其上下文被取消，由处理函数自行退出，且不再重试
 func (tw *TimingWheel) CancelTask(taskID string) error {
-	// 实现任务取消逻辑
+	tw.tasksLock.Lock()
+	defer tw.tasksLock.Unlock()
+
+	t, ok := tw.tasks[taskID]
+	if ok {
+		delete(tw.tasks, taskID)
+	} else if t, ok = tw.runningTasks[taskID]; ok {
+		delete(tw.runningTasks, taskID)
+		if t.cancelRun != nil {
+			t.cancelRun()
+		}
+	} else {
+		return ErrTaskNotFound
+	}
+	t.cancelled = true
+	tw.metrics.taskCancelled()
 	return nil
 }
 
-// UpdateTask 更新任务执行时间
+// UpdateTask 将未触发的任务改为 newDuration 之后触发
 func (tw *TimingWheel) UpdateTask(taskID string, newDuration time.Duration) error {
-	// 实现任务更新逻辑
+	if newDuration < 0 {
+		return fmt.Errorf("duration不能为负数")
+	}
+
+	tw.tasksLock.Lock()
+	defer tw.tasksLock.Unlock()
+
+	t, ok := tw.tasks[taskID]
+	if !ok {
+		return ErrTaskNotFound
+	}
+	// 原任务已在槽位中，标记取消后以副本重新调度
+	nt := t.clone()
+	t.cancelled = true
+	tw.tasks[taskID] = nt
+	tw.schedule(nt, newDuration)
 	return nil
 }
 
-func (tw *TimingWheel) processTask(t *task) {
-	if tw.enableMetrics {
-		tw.metrics.mutex.Lock()
-		tw.metrics.ProcessingTasks++
-		tw.metrics.mutex.Unlock()
+func (tw *TimingWheel) isCancelled(t *task) bool {
+	tw.tasksLock.Lock()
+	defer tw.tasksLock.Unlock()
+	return t.cancelled
+}
+
+// takeTask 将到期任务从待触发列表移入已分发列表，返回任务是否仍需执行
+func (tw *TimingWheel) takeTask(t *task) bool {
+	tw.tasksLock.Lock()
+	defer tw.tasksLock.Unlock()
+
+	if t.cancelled {
+		return false
+	}
+	if tw.tasks[t.id] == t {
+		delete(tw.tasks, t.id)
+	}
+	tw.runningTasks[t.id] = t
+	return true
+}
+
+// runTask 执行任务并记录调度延迟和处理耗时
+func (tw *TimingWheel) runTask(rs *runState, t *task) {
+	tc := tw.newTaskContext(rs.ctx, t)
+	if tc == nil {
+		return
 	}
+	defer tc.cancel()
 
-	if tw.pool != nil {
-		if err := tw.pool.Submit(func() {
-			defer tw.taskCompleted(t)
-			t.handle()
-		}); err != nil {
-			tw.handleTaskError(t, err)
+	tw.metrics.taskStarted(tc.firedAt.Sub(tc.scheduledAt))
+	panicked, err := t.handle(tc)
+	tw.metrics.taskFinished(tw.clock.Now().Sub(tc.firedAt), panicked, err)
+	if err != nil {
+		tw.recordFailure(t, tc.attempt, err)
+	}
+	if tw.finishRunning(t) && err != nil {
+		tw.handleFailure(t, err)
+	}
+}
+
+// finishRunning 将任务移出已分发列表，返回任务是否未被取消
+func (tw *TimingWheel) finishRunning(t *task) bool {
+	tw.tasksLock.Lock()
+	defer tw.tasksLock.Unlock()
+
+	if tw.runningTasks[t.id] == t {
+		delete(tw.runningTasks, t.id)
+	}
+	t.cancelRun = nil
+	return !t.cancelled
+}
+
+// handleFailure 任务执行失败时按重试策略重新调度，重试耗尽后投递死信
+func (tw *TimingWheel) handleFailure(t *task, err error) {
+	if t.retry != nil && t.attempt < t.retry.MaxAttempts {
+		// 优雅停止期间仍允许重试任务回到时间轮，随未触发任务一起返回
+		if tw.retryTask(t) {
+			return
 		}
-	} else {
-		go func() {
-			defer tw.taskCompleted(t)
-			t.handle()
-		}()
+		GetLogger().Warnf("时间轮已停止，任务不再重试: %v", err)
 	}
+	tw.sendDeadLetter(DeadLetter{Data: t.data, Err: err, Attempts: t.attempt})
 }
 
-func (tw *TimingWheel) taskCompleted(t *task) {
-	if !tw.enableMetrics {
-		return
+// retryTask 重新注册并调度重试任务，期间已添加同ID的新任务时放弃重试；时间轮已停止时返回 false。
+// 持有 tasksLock 检查状态，Shutdown 取出未触发任务后不会再有重试任务放回时间轮
+func (tw *TimingWheel) retryTask(t *task) bool {
+	tw.tasksLock.Lock()
+	defer tw.tasksLock.Unlock()
+
+	if !tw.acceptsRetry() {
+		return false
+	}
+	if _, ok := tw.tasks[t.id]; ok {
+		GetLogger().Debugf("任务 %s 已被替换，放弃重试", t.id)
+		return true
 	}
-	tw.metrics.mutex.Lock()
-	tw.metrics.CompletedTasks++
-	tw.metrics.ProcessingTasks--
-	tw.metrics.mutex.Unlock()
+	tw.tasks[t.id] = t
+	tw.schedule(t, t.retry.backoff(t.attempt))
+	tw.metrics.taskRetried()
+	return true
 }
 
 func (tw *TimingWheel) handleTaskError(t *task, err error) {
-	if tw.enableMetrics {
-		tw.metrics.mutex.Lock()
-		tw.metrics.FailedTasks++
-		tw.metrics.ProcessingTasks--
-		tw.metrics.mutex.Unlock()
-	}
+	tw.finishRunning(t)
+	tw.metrics.taskDispatchFailed()
+	tw.recordFailure(t, 0, err)
 
 	if tw.submitErrHandler != nil {
 		tw.submitErrHandler(t.data, err)
 	} else {
-		GetLogger().Errorf("提交任务到协程池失败: %v", err)
+		GetLogger().Errorf("任务分发失败: %v", err)
 	}
 }
 
-func (tw *TimingWheel) reinsertTask(t *task) {
-	node := tw.nodes[(tw.current+1)%tw.scale]
+func (tw *TimingWheel) reinsertTask(node *node, t *task) {
 	node.lock.Lock()
 	if tw.maxTasksPerSlot > 0 && len(node.tasks) >= tw.maxTasksPerSlot {
 		GetLogger().Warnf("槽位任务数超过限制: %d", tw.maxTasksPerSlot)
@@ -351,12 +668,3 @@ func (tw *TimingWheel) reinsertTask(t *task) {
 	node.tasks = append(node.tasks, t)
 	node.lock.Unlock()
 }
-
-// 添加指标结构
-type Metrics struct {
-	TotalTasks      int64
-	CompletedTasks  int64
-	FailedTasks     int64
-	ProcessingTasks int64
-	mutex           sync.RWMutex
-}
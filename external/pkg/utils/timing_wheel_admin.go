@@ -0,0 +1,223 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 默认保留的最近失败记录数
+const defaultFailureHistory = 100
+
+// TaskState 任务状态
+type TaskState string
+
+const (
+	TaskPending TaskState = "pending" // 在槽位中等待触发
+	TaskRunning TaskState = "running" // 已分发，排队或执行中
+)
+
+// TaskInfo 任务信息，用于运维查看
+type TaskInfo struct {
+	ID       string            `json:"id"`
+	State    TaskState         `json:"state"`
+	Due      time.Time         `json:"due"`              // 预期触发时间
+	Slot     int               `json:"slot"`             // 所在槽位，已分发的任务为 -1
+	Round    uint64            `json:"round"`            // 剩余圈数
+	Priority int               `json:"priority"`         // 优先级
+	Attempts int               `json:"attempts"`         // 已执行次数
+	Labels   map[string]string `json:"labels,omitempty"` // 任务标签
+	Data     any               `json:"-"`                // 任务数据，不参与序列化
+}
+
+// TaskFailure 任务失败记录
+type TaskFailure struct {
+	ID      string            `json:"id"`
+	Attempt int               `json:"attempt"` // 失败时的执行次数，分发失败时为 0
+	Error   string            `json:"error"`
+	At      time.Time         `json:"at"`
+	Labels  map[string]string `json:"labels,omitempty"`
+}
+
+// failureLog 固定容量的失败记录环形缓冲
+type failureLog struct {
+	mutex   sync.Mutex
+	records []TaskFailure
+	next    int
+	full    bool
+}
+
+func newFailureLog(size int) *failureLog {
+	if size <= 0 {
+		return nil
+	}
+	return &failureLog{records: make([]TaskFailure, size)}
+}
+
+func (l *failureLog) add(f TaskFailure) {
+	if l == nil {
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.records[l.next] = f
+	l.next = (l.next + 1) % len(l.records)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// list 按时间倒序返回失败记录
+func (l *failureLog) list() []TaskFailure {
+	if l == nil {
+		return nil
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	n := l.next
+	if l.full {
+		n = len(l.records)
+	}
+	res := make([]TaskFailure, 0, n)
+	for i := 1; i <= n; i++ {
+		res = append(res, l.records[(l.next-i+len(l.records))%len(l.records)])
+	}
+	return res
+}
+
+// WithFailureHistory 设置保留的最近失败记录数，默认100，小于等于0表示不记录
+func WithFailureHistory(size int) TimingWheelOption {
+	return func(tw *TimingWheel) {
+		tw.failureHistory = size
+	}
+}
+
+// recordFailure 记录任务执行或分发失败
+func (tw *TimingWheel) recordFailure(t *task, attempt int, err error) {
+	tw.failures.add(TaskFailure{
+		ID:      t.id,
+		Attempt: attempt,
+		Error:   err.Error(),
+		At:      tw.clock.Now(),
+		Labels:  t.labels,
+	})
+}
+
+// RecentFailures 按时间倒序返回最近的失败记录
+func (tw *TimingWheel) RecentFailures() []TaskFailure {
+	return tw.failures.list()
+}
+
+// Tasks 返回所有未取消的任务，按预期触发时间排序。
+// 恰好在 tick 中被取出的任务可能不在结果中。
+func (tw *TimingWheel) Tasks() []TaskInfo {
+	tw.tasksLock.Lock()
+	defer tw.tasksLock.Unlock()
+
+	var infos []TaskInfo
+	for _, n := range tw.nodes {
+		n.lock.Lock()
+		for _, t := range n.tasks {
+			if t.cancelled {
+				continue
+			}
+			info := newTaskInfo(t, TaskPending)
+			info.Slot = int(n.index)
+			info.Round = t.round
+			infos = append(infos, info)
+		}
+		n.lock.Unlock()
+	}
+	for _, t := range tw.runningTasks {
+		info := newTaskInfo(t, TaskRunning)
+		info.Slot = -1
+		infos = append(infos, info)
+	}
+
+	sort.SliceStable(infos, func(i, j int) bool {
+		return infos[i].Due.Before(infos[j].Due)
+	})
+	return infos
+}
+
+func newTaskInfo(t *task, state TaskState) TaskInfo {
+	return TaskInfo{
+		ID:       t.id,
+		State:    state,
+		Due:      t.due,
+		Priority: t.priority,
+		Attempts: t.attempt,
+		Labels:   t.labels,
+		Data:     t.data,
+	}
+}
+
+// PeekDue 返回最早触发的 n 个未触发任务
+func (tw *TimingWheel) PeekDue(n int) []TaskInfo {
+	var infos []TaskInfo
+	for _, info := range tw.Tasks() {
+		if len(infos) >= n {
+			break
+		}
+		if info.State == TaskPending {
+			infos = append(infos, info)
+		}
+	}
+	return infos
+}
+
+// SlotCounts 返回每个槽位中未取消的任务数
+func (tw *TimingWheel) SlotCounts() []int {
+	tw.tasksLock.Lock()
+	defer tw.tasksLock.Unlock()
+
+	counts := make([]int, len(tw.nodes))
+	for i, n := range tw.nodes {
+		n.lock.Lock()
+		for _, t := range n.tasks {
+			if !t.cancelled {
+				counts[i]++
+			}
+		}
+		n.lock.Unlock()
+	}
+	return counts
+}
+
+// FireNow 立即分发未触发的任务，不等待其所在槽位到期。
+// 分发队列已满时返回 ErrQueueFull，任务保持原计划不变。
+func (tw *TimingWheel) FireNow(taskID string) error {
+	tw.tasksLock.Lock()
+	defer tw.tasksLock.Unlock()
+
+	// 持有 tw.lock 期间时间轮不会停止，分发队列不会被关闭
+	tw.lock.Lock()
+	defer tw.lock.Unlock()
+
+	if tw.status != running {
+		return fmt.Errorf("时间轮未启动")
+	}
+	t, ok := tw.tasks[taskID]
+	if !ok {
+		return ErrTaskNotFound
+	}
+
+	// 原任务留在槽位中并标记取消，以副本分发
+	nt := t.clone()
+	nt.due = tw.clock.Now()
+	rs := tw.rs
+	rs.running.Add(1)
+	select {
+	case rs.taskQueue <- nt:
+	default:
+		rs.running.Done()
+		return ErrQueueFull
+	}
+	t.cancelled = true
+	delete(tw.tasks, taskID)
+	tw.runningTasks[taskID] = nt
+	return nil
+}
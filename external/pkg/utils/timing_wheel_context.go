@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"context"
+	"time"
+)
+
+// TaskContext 任务上下文接口
+type TaskContext interface {
+	AddTask(data any, handler TaskHandler, duration time.Duration) error
+}
+
+// TaskRunContext 任务执行期间的上下文，时间轮传给处理函数的 TaskContext 均实现该接口：
+//
+//	rc, ok := tc.(TaskRunContext)
+type TaskRunContext interface {
+	TaskContext
+	// Context 任务执行期间有效，任务被取消、执行超时或时间轮停止时结束
+	Context() context.Context
+	// TaskID 任务ID
+	TaskID() string
+	// ScheduledAt 预期触发时间
+	ScheduledAt() time.Time
+	// FiredAt 实际触发时间
+	FiredAt() time.Time
+	// Attempt 当前执行次数，从1开始
+	Attempt() int
+	// Labels 添加任务时设置的标签的副本
+	Labels() map[string]string
+}
+
+// WithTaskTimeout 设置任务单次执行的超时时间，超时后任务上下文结束，超时基于系统时间
+func WithTaskTimeout(timeout time.Duration) TaskOption {
+	return func(t *task) {
+		t.timeout = timeout
+	}
+}
+
+// WithLabels 设置任务标签，用于日志和排查
+func WithLabels(labels map[string]string) TaskOption {
+	return func(t *task) {
+		t.labels = make(map[string]string, len(labels))
+		for k, v := range labels {
+			t.labels[k] = v
+		}
+	}
+}
+
+type taskContext struct {
+	ctx         context.Context
+	cancel      context.CancelFunc
+	tw          *TimingWheel
+	t           *task
+	scheduledAt time.Time
+	firedAt     time.Time
+	attempt     int
+}
+
+// newTaskContext 创建任务上下文，以便执行期间 CancelTask 取消，任务已被取消时返回nil
+func (tw *TimingWheel) newTaskContext(parent context.Context, t *task) *taskContext {
+	tw.tasksLock.Lock()
+	defer tw.tasksLock.Unlock()
+
+	if t.cancelled {
+		return nil
+	}
+	t.attempt++
+	tc := &taskContext{
+		tw:          tw,
+		t:           t,
+		scheduledAt: t.due,
+		firedAt:     tw.clock.Now(),
+		attempt:     t.attempt,
+	}
+	if t.timeout > 0 {
+		tc.ctx, tc.cancel = context.WithTimeout(parent, t.timeout)
+	} else {
+		tc.ctx, tc.cancel = context.WithCancel(parent)
+	}
+	t.cancelRun = tc.cancel
+	return tc
+}
+
+func (tc *taskContext) AddTask(data any, handler TaskHandler, duration time.Duration) error {
+	return tc.tw.AddTask(data, handler, duration)
+}
+
+func (tc *taskContext) Context() context.Context {
+	return tc.ctx
+}
+
+func (tc *taskContext) TaskID() string {
+	return tc.t.id
+}
+
+func (tc *taskContext) ScheduledAt() time.Time {
+	return tc.scheduledAt
+}
+
+func (tc *taskContext) FiredAt() time.Time {
+	return tc.firedAt
+}
+
+func (tc *taskContext) Attempt() int {
+	return tc.attempt
+}
+
+func (tc *taskContext) Labels() map[string]string {
+	// 同一任务的多次执行共享标签，返回副本避免处理函数并发修改
+	labels := make(map[string]string, len(tc.t.labels))
+	for k, v := range tc.t.labels {
+		labels[k] = v
+	}
+	return labels
+}
@@ -0,0 +1,115 @@
+package utils
+
+import "errors"
+
+// 未使用协程池时默认的分发协程数
+const defaultWorkers = 64
+
+var (
+	// ErrQueueFull 分发队列已满，任务被拒绝
+	ErrQueueFull = errors.New("任务队列已满")
+	// ErrTaskDropped 分发队列已满，最早入队的任务被丢弃
+	ErrTaskDropped = errors.New("任务队列已满，最早入队的任务被丢弃")
+)
+
+// OverflowStrategy 分发队列满时的处理策略
+type OverflowStrategy int8
+
+const (
+	OverflowBlock      OverflowStrategy = iota // 阻塞tick直到队列有空位
+	OverflowDropOldest                         // 丢弃最早入队的任务，通过 SubmitErrorHandler 报告 ErrTaskDropped
+	OverflowReject                             // 拒绝新任务，通过 SubmitErrorHandler 报告 ErrQueueFull
+)
+
+// WithQueueSize 设置到期任务分发队列的容量，默认1000
+func WithQueueSize(size int) TimingWheelOption {
+	return func(tw *TimingWheel) {
+		tw.queueSize = size
+	}
+}
+
+// WithWorkers 设置从分发队列取任务的协程数。
+// 未使用协程池时即为任务最大并发数，默认64；使用协程池时默认1，并发度由池大小控制。
+// 任务按入队顺序出队，设置为1且不使用协程池时同一槽位的任务严格按顺序执行。
+func WithWorkers(n int) TimingWheelOption {
+	return func(tw *TimingWheel) {
+		tw.workers = n
+	}
+}
+
+// WithOverflowStrategy 设置分发队列满时的处理策略，默认阻塞
+func WithOverflowStrategy(strategy OverflowStrategy) TimingWheelOption {
+	return func(tw *TimingWheel) {
+		tw.overflow = strategy
+	}
+}
+
+// enqueue 将到期任务放入分发队列，next 为时间轮停止时放回任务的槽位
+func (tw *TimingWheel) enqueue(rs *runState, next *node, t *task) {
+	rs.running.Add(1)
+	switch tw.overflow {
+	case OverflowReject:
+		select {
+		case rs.taskQueue <- t:
+		default:
+			rs.running.Done()
+			tw.handleTaskError(t, ErrQueueFull)
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case rs.taskQueue <- t:
+				return
+			default:
+			}
+			select {
+			case old := <-rs.taskQueue:
+				rs.running.Done()
+				tw.handleTaskError(old, ErrTaskDropped)
+			default:
+			}
+		}
+	default:
+		select {
+		case rs.taskQueue <- t:
+		case <-rs.stop:
+			// 阻塞期间时间轮停止，任务放回时间轮，随未触发任务一起返回
+			rs.running.Done()
+			tw.requeue(next, t)
+		}
+	}
+}
+
+// requeue 将已取出但未分发的任务重新注册并放回槽位
+func (tw *TimingWheel) requeue(n *node, t *task) {
+	tw.tasksLock.Lock()
+	defer tw.tasksLock.Unlock()
+
+	if tw.runningTasks[t.id] == t {
+		delete(tw.runningTasks, t.id)
+	}
+	if _, ok := tw.tasks[t.id]; ok || t.cancelled {
+		return
+	}
+	tw.tasks[t.id] = t
+	t.round = 0
+	tw.reinsertTask(n, t)
+}
+
+// worker 从分发队列取出任务执行，使用协程池时提交到池中执行
+func (tw *TimingWheel) worker(rs *runState) {
+	for t := range rs.taskQueue {
+		if rs.pool == nil {
+			tw.runTask(rs, t)
+			rs.running.Done()
+			continue
+		}
+		if err := rs.pool.Submit(func() {
+			defer rs.running.Done()
+			tw.runTask(rs, t)
+		}); err != nil {
+			rs.running.Done()
+			tw.handleTaskError(t, err)
+		}
+	}
+}
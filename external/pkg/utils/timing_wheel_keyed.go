@@ -0,0 +1,28 @@
+package utils
+
+import "time"
+
+// KeyPolicy 按业务键添加任务时，键已存在的处理策略
+type KeyPolicy int8
+
+const (
+	KeyReplace      KeyPolicy = iota // 取消已存在的任务，以新任务替换
+	KeyIgnore                        // 保留已存在的任务，忽略新任务
+	KeyKeepEarliest                  // 保留触发时间较早的任务
+)
+
+// WithTaskKey 以业务键作为任务ID，键已存在时按 policy 处理
+func WithTaskKey(key string, policy KeyPolicy) TaskOption {
+	return func(t *task) {
+		t.id = key
+		t.keyPolicy = &policy
+	}
+}
+
+// AddKeyedTask 添加按业务键去重的定时任务，可用于防抖场景，任务ID即为 key
+func (tw *TimingWheel) AddKeyedTask(key string, data any, handler TaskHandler, duration time.Duration, policy KeyPolicy, opts ...TaskOption) error {
+	return tw.AddErrTask(data, func(data any, tc TaskContext) error {
+		handler(data, tc)
+		return nil
+	}, duration, append(opts, WithTaskKey(key, policy))...)
+}
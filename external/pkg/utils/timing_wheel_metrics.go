@@ -0,0 +1,317 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// 调度延迟直方图默认分桶
+	defaultLagBuckets = []time.Duration{
+		10 * time.Millisecond, 50 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond,
+		500 * time.Millisecond, time.Second, 2500 * time.Millisecond, 5 * time.Second,
+		10 * time.Second, 30 * time.Second, time.Minute,
+	}
+	// 处理耗时直方图默认分桶
+	defaultLatencyBuckets = []time.Duration{
+		time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond,
+		50 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+		time.Second, 2500 * time.Millisecond, 5 * time.Second, 10 * time.Second,
+	}
+)
+
+// Metrics 时间轮指标，所有计数器均为原子操作，nil 接收者上的记录方法为空操作
+type Metrics struct {
+	// Deprecated: 使用 Snapshot().Added，值为 GetMetrics 调用时的计数
+	TotalTasks int64
+	// Deprecated: 使用 Snapshot().Completed，值为 GetMetrics 调用时的计数
+	CompletedTasks int64
+	// Deprecated: 使用 Snapshot().Failed、Snapshot().Panicked 和 Snapshot().DispatchFailed，值为 GetMetrics 调用时三者之和
+	FailedTasks int64
+	// Deprecated: 使用 Snapshot().Processing，值为 GetMetrics 调用时的计数
+	ProcessingTasks int64
+
+	*metricCounters
+}
+
+// metricCounters 实时计数，GetMetrics 返回的副本与时间轮共享
+type metricCounters struct {
+	added          atomic.Int64
+	cancelled      atomic.Int64
+	fired          atomic.Int64
+	completed      atomic.Int64
+	failed         atomic.Int64
+	panicked       atomic.Int64
+	dispatchFailed atomic.Int64
+	retried        atomic.Int64
+	deadLetter     atomic.Int64
+	processing     atomic.Int64
+
+	schedulingLag  *Histogram
+	handlerLatency *Histogram
+}
+
+func newMetrics() *Metrics {
+	return &Metrics{metricCounters: &metricCounters{
+		schedulingLag:  NewHistogram(defaultLagBuckets),
+		handlerLatency: NewHistogram(defaultLatencyBuckets),
+	}}
+}
+
+// withLegacyFields 返回共享实时计数的副本，并以当前计数填充已废弃的字段
+func (m *Metrics) withLegacyFields() *Metrics {
+	if m == nil {
+		return nil
+	}
+	return &Metrics{
+		TotalTasks:      m.added.Load(),
+		CompletedTasks:  m.completed.Load(),
+		FailedTasks:     m.failed.Load() + m.panicked.Load() + m.dispatchFailed.Load(),
+		ProcessingTasks: m.processing.Load(),
+		metricCounters:  m.metricCounters,
+	}
+}
+
+func (m *Metrics) taskAdded() {
+	if m == nil {
+		return
+	}
+	m.added.Add(1)
+}
+
+func (m *Metrics) taskCancelled() {
+	if m == nil {
+		return
+	}
+	m.cancelled.Add(1)
+}
+
+// taskDispatchFailed 记录任务因队列已满、被丢弃或协程池提交失败而未能执行
+func (m *Metrics) taskDispatchFailed() {
+	if m == nil {
+		return
+	}
+	m.dispatchFailed.Add(1)
+}
+
+func (m *Metrics) taskRetried() {
+	if m == nil {
+		return
+	}
+	m.retried.Add(1)
+}
+
+func (m *Metrics) taskDeadLettered() {
+	if m == nil {
+		return
+	}
+	m.deadLetter.Add(1)
+}
+
+// taskStarted 记录任务开始执行，lag 为实际触发时间与预期触发时间之差
+func (m *Metrics) taskStarted(lag time.Duration) {
+	if m == nil {
+		return
+	}
+	m.fired.Add(1)
+	m.processing.Add(1)
+	if lag < 0 {
+		lag = 0
+	}
+	m.schedulingLag.Observe(lag)
+}
+
+// taskFinished 记录任务执行结束
+func (m *Metrics) taskFinished(latency time.Duration, panicked bool, err error) {
+	if m == nil {
+		return
+	}
+	m.processing.Add(-1)
+	m.handlerLatency.Observe(latency)
+	switch {
+	case panicked:
+		m.panicked.Add(1)
+	case err != nil:
+		m.failed.Add(1)
+	default:
+		m.completed.Add(1)
+	}
+}
+
+// Snapshot 获取计数器和直方图的快照
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	if m == nil {
+		return MetricsSnapshot{}
+	}
+	return MetricsSnapshot{
+		Added:          m.added.Load(),
+		Cancelled:      m.cancelled.Load(),
+		Fired:          m.fired.Load(),
+		Completed:      m.completed.Load(),
+		Failed:         m.failed.Load(),
+		Panicked:       m.panicked.Load(),
+		DispatchFailed: m.dispatchFailed.Load(),
+		Retried:        m.retried.Load(),
+		DeadLettered:   m.deadLetter.Load(),
+		Processing:     m.processing.Load(),
+		SchedulingLag:  m.schedulingLag.Snapshot(),
+		HandlerLatency: m.handlerLatency.Snapshot(),
+	}
+}
+
+// MetricsSnapshot 时间轮指标快照
+type MetricsSnapshot struct {
+	Added          int64 // 已添加任务数
+	Cancelled      int64 // 已取消任务数
+	Fired          int64 // 已触发任务数
+	Completed      int64 // 正常完成任务数
+	Failed         int64 // 失败任务数
+	Panicked       int64 // 发生panic的任务数
+	DispatchFailed int64 // 分发失败未执行的任务数
+	Retried        int64 // 重试次数
+	DeadLettered   int64 // 投递到死信的任务数
+	Processing     int64 // 正在执行的任务数
+
+	SlotTasks      []int // 每个槽位中未取消的任务数，与 SlotCounts 一致
+	SchedulingLag  HistogramSnapshot
+	HandlerLatency HistogramSnapshot
+}
+
+// Pending 返回所有槽位中待触发任务总数
+func (s MetricsSnapshot) Pending() int {
+	total := 0
+	for _, n := range s.SlotTasks {
+		total += n
+	}
+	return total
+}
+
+// WritePrometheus 以 Prometheus 文本格式输出指标，namespace 作为指标名前缀
+func (s MetricsSnapshot) WritePrometheus(w io.Writer, namespace string) error {
+	bw := bufio.NewWriter(w)
+
+	counters := []struct {
+		name  string
+		help  string
+		value int64
+	}{
+		{"tasks_added_total", "已添加任务数", s.Added},
+		{"tasks_cancelled_total", "已取消任务数", s.Cancelled},
+		{"tasks_fired_total", "已触发任务数", s.Fired},
+		{"tasks_completed_total", "正常完成任务数", s.Completed},
+		{"tasks_failed_total", "失败任务数", s.Failed},
+		{"tasks_panicked_total", "发生panic的任务数", s.Panicked},
+		{"tasks_dispatch_failed_total", "分发失败未执行的任务数", s.DispatchFailed},
+		{"tasks_retried_total", "重试次数", s.Retried},
+		{"tasks_dead_lettered_total", "投递到死信的任务数", s.DeadLettered},
+	}
+	for _, c := range counters {
+		name := namespace + "_" + c.name
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, c.help, name, name, c.value)
+	}
+
+	name := namespace + "_tasks_processing"
+	fmt.Fprintf(bw, "# HELP %s 正在执行的任务数\n# TYPE %s gauge\n%s %d\n", name, name, name, s.Processing)
+
+	name = namespace + "_tasks_pending"
+	fmt.Fprintf(bw, "# HELP %s 待触发任务数\n# TYPE %s gauge\n%s %d\n", name, name, name, s.Pending())
+
+	name = namespace + "_slot_tasks"
+	fmt.Fprintf(bw, "# HELP %s 槽位任务数\n# TYPE %s gauge\n", name, name)
+	for i, n := range s.SlotTasks {
+		fmt.Fprintf(bw, "%s{slot=\"%d\"} %d\n", name, i, n)
+	}
+
+	writePrometheusHistogram(bw, namespace+"_scheduling_lag_seconds", "实际触发时间与预期触发时间之差", s.SchedulingLag)
+	writePrometheusHistogram(bw, namespace+"_handler_duration_seconds", "任务处理耗时", s.HandlerLatency)
+
+	return bw.Flush()
+}
+
+func writePrometheusHistogram(w io.Writer, name, help string, h HistogramSnapshot) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	for _, b := range h.Buckets {
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, strconv.FormatFloat(b.UpperBound.Seconds(), 'g', -1, 64), b.Count)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.Count)
+	fmt.Fprintf(w, "%s_sum %s\n", name, strconv.FormatFloat(h.Sum.Seconds(), 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count %d\n", name, h.Count)
+}
+
+// Histogram 基于固定分桶的并发安全直方图
+type Histogram struct {
+	bounds []time.Duration
+	counts []atomic.Uint64 // 最后一个为 +Inf 桶
+	count  atomic.Uint64
+	sum    atomic.Int64
+}
+
+// NewHistogram 创建直方图，bounds 须按升序排列
+func NewHistogram(bounds []time.Duration) *Histogram {
+	return &Histogram{
+		bounds: bounds,
+		counts: make([]atomic.Uint64, len(bounds)+1),
+	}
+}
+
+// Observe 记录一次观测值
+func (h *Histogram) Observe(d time.Duration) {
+	i := 0
+	for i < len(h.bounds) && d > h.bounds[i] {
+		i++
+	}
+	h.counts[i].Add(1)
+	h.count.Add(1)
+	h.sum.Add(int64(d))
+}
+
+// Snapshot 获取直方图快照，桶计数为累计值
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	s := HistogramSnapshot{
+		Buckets: make([]HistogramBucket, len(h.bounds)),
+		Sum:     time.Duration(h.sum.Load()),
+	}
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i].Load()
+		s.Buckets[i] = HistogramBucket{UpperBound: bound, Count: cumulative}
+	}
+	s.Count = cumulative + h.counts[len(h.bounds)].Load()
+	return s
+}
+
+// HistogramSnapshot 直方图快照
+type HistogramSnapshot struct {
+	Buckets []HistogramBucket
+	Count   uint64
+	Sum     time.Duration
+}
+
+// HistogramBucket 直方图分桶，Count 为小于等于 UpperBound 的累计观测数
+type HistogramBucket struct {
+	UpperBound time.Duration
+	Count      uint64
+}
+
+// MetricsSnapshot 获取时间轮指标快照，包含各槽位的任务占用情况，未启用指标时返回nil
+func (tw *TimingWheel) MetricsSnapshot() *MetricsSnapshot {
+	if tw.metrics == nil {
+		return nil
+	}
+	s := tw.metrics.Snapshot()
+	s.SlotTasks = tw.SlotCounts()
+	return &s
+}
+
+// WritePrometheus 以 Prometheus 文本格式输出时间轮指标
+func (tw *TimingWheel) WritePrometheus(w io.Writer) error {
+	s := tw.MetricsSnapshot()
+	if s == nil {
+		return fmt.Errorf("时间轮未启用指标收集")
+	}
+	return s.WritePrometheus(w, "timing_wheel")
+}
@@ -0,0 +1,89 @@
+package utils
+
+import "time"
+
+// BackoffStrategy 重试退避策略
+type BackoffStrategy int8
+
+const (
+	BackoffFixed       BackoffStrategy = iota // 固定间隔
+	BackoffExponential                        // 指数退避
+)
+
+// TaskRetryPolicy 任务重试策略，重试任务通过时间轮重新调度
+type TaskRetryPolicy struct {
+	MaxAttempts int             // 最大执行次数（含首次），小于等于1表示不重试
+	Backoff     BackoffStrategy // 退避策略
+	Delay       time.Duration   // 重试间隔，指数退避时为首次重试间隔
+	MaxDelay    time.Duration   // 指数退避的最大间隔，0 表示不限制
+}
+
+// backoff 计算第 attempt 次执行失败后的重试间隔
+func (p *TaskRetryPolicy) backoff(attempt int) time.Duration {
+	if p.Backoff != BackoffExponential || attempt <= 1 {
+		return p.Delay
+	}
+	delay := p.Delay
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return delay
+}
+
+// WithTaskRetry 设置任务重试策略
+func WithTaskRetry(policy TaskRetryPolicy) TaskOption {
+	return func(t *task) {
+		t.retry = &policy
+	}
+}
+
+// DeadLetter 重试耗尽后仍失败的任务
+type DeadLetter struct {
+	Data     any   // 任务数据
+	Err      error // 最后一次执行的错误
+	Attempts int   // 已执行次数
+}
+
+// DeadLetterHandler 死信处理函数
+type DeadLetterHandler func(dl DeadLetter)
+
+// WithDeadLetterHandler 设置死信处理函数
+func WithDeadLetterHandler(handler DeadLetterHandler) TimingWheelOption {
+	return func(tw *TimingWheel) {
+		tw.deadLetter = handler
+	}
+}
+
+// WithDeadLetterQueue 启用容量为 size 的死信队列，通过 DeadLetters 读取
+func WithDeadLetterQueue(size int) TimingWheelOption {
+	return func(tw *TimingWheel) {
+		tw.deadLetters = make(chan DeadLetter, size)
+	}
+}
+
+// DeadLetters 返回死信队列，未启用时返回nil
+func (tw *TimingWheel) DeadLetters() <-chan DeadLetter {
+	return tw.deadLetters
+}
+
+// sendDeadLetter 投递死信，死信队列已满时丢弃并记录日志
+func (tw *TimingWheel) sendDeadLetter(dl DeadLetter) {
+	tw.metrics.taskDeadLettered()
+
+	if tw.deadLetter != nil {
+		tw.deadLetter(dl)
+	}
+	if tw.deadLetters != nil {
+		select {
+		case tw.deadLetters <- dl:
+		default:
+			GetLogger().Warnf("死信队列已满，丢弃任务: %v", dl.Err)
+		}
+	}
+	if tw.deadLetter == nil && tw.deadLetters == nil {
+		GetLogger().Errorf("任务执行失败（共执行 %d 次）: %v", dl.Attempts, dl.Err)
+	}
+}
@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// PendingTask 优雅停止时尚未触发的任务，可持久化后通过 Restore 重新调度
+type PendingTask struct {
+	ID        string
+	Data      any
+	Handler   ErrTaskHandler
+	Due       time.Time // 预期触发时间
+	Attempts  int       // 已执行次数，大于0表示等待重试
+	Priority  int
+	Timeout   time.Duration // 单次执行超时时间，0 表示不限制
+	Labels    map[string]string
+	KeyPolicy *KeyPolicy       // 按业务键添加时的去重策略，nil 表示未设置
+	Retry     *TaskRetryPolicy // 重试策略，nil 表示不重试
+}
+
+// PendingTaskHandler 未触发任务处理函数
+type PendingTaskHandler func(tasks []PendingTask)
+
+// WithPendingTaskHandler 设置 Shutdown 时未触发任务的处理函数
+func WithPendingTaskHandler(handler PendingTaskHandler) TimingWheelOption {
+	return func(tw *TimingWheel) {
+		tw.pendingHandler = handler
+	}
+}
+
+// Shutdown 优雅停止时间轮：拒绝新任务，等待运行中的任务结束，返回按触发时间排序的未触发任务。
+// ctx 结束时取消运行中任务的上下文并不再等待其结束，返回 ctx 的错误，未触发任务仍会返回；
+// 此后结束的任务不再重试，失败时直接投递死信，因此返回的任务不会再被执行。
+// 停止后可再次 Start。
+func (tw *TimingWheel) Shutdown(ctx context.Context) ([]PendingTask, error) {
+	tw.lock.Lock()
+	if tw.status != running {
+		tw.lock.Unlock()
+		return nil, fmt.Errorf("时间轮未运行")
+	}
+	tw.status = stopping
+	rs := tw.rs
+	close(rs.stop)
+	tw.lock.Unlock()
+
+	released := make(chan struct{})
+	go func() {
+		rs.release()
+		close(released)
+	}()
+
+	var err error
+	select {
+	case <-released:
+	case <-ctx.Done():
+		err = ctx.Err()
+		// 不再等待，取消运行中任务的上下文通知其尽快退出
+		rs.cancel()
+		GetLogger().Warnf("等待运行中的任务结束超时: %v", err)
+		// 调度循环退出后不再有任务从槽位取出或放回
+		<-rs.done
+	}
+	rs.cancel()
+
+	pending := tw.drain()
+
+	if tw.pendingHandler != nil {
+		tw.pendingHandler(pending)
+	}
+	return pending, err
+}
+
+// drain 取出所有槽位中未取消的任务并将时间轮置为已停止。
+// 重试任务在持有 tasksLock 时检查状态，因此取出后仍在执行的任务不会再放回时间轮
+func (tw *TimingWheel) drain() []PendingTask {
+	tw.tasksLock.Lock()
+	defer tw.tasksLock.Unlock()
+
+	tw.lock.Lock()
+	tw.status = stopped
+	tw.lock.Unlock()
+
+	var pending []PendingTask
+	for _, n := range tw.nodes {
+		n.lock.Lock()
+		for _, t := range n.tasks {
+			if t.cancelled {
+				continue
+			}
+			pending = append(pending, PendingTask{
+				ID:        t.id,
+				Data:      t.data,
+				Handler:   t.handler,
+				Due:       t.due,
+				Attempts:  t.attempt,
+				Priority:  t.priority,
+				Timeout:   t.timeout,
+				Labels:    t.labels,
+				KeyPolicy: t.keyPolicy,
+				Retry:     t.retry,
+			})
+		}
+		n.tasks = nil
+		n.lock.Unlock()
+	}
+	tw.tasks = make(map[string]*task)
+	sort.SliceStable(pending, func(i, j int) bool {
+		return pending[i].Due.Before(pending[j].Due)
+	})
+	return pending
+}
+
+// Restore 重新调度未触发的任务，已过期的任务在下一次tick触发，ID 已存在的任务被跳过
+func (tw *TimingWheel) Restore(tasks []PendingTask) error {
+	if !tw.isRunning() {
+		return fmt.Errorf("时间轮未启动")
+	}
+	for _, p := range tasks {
+		if p.Handler == nil {
+			return fmt.Errorf("任务处理函数不能为空")
+		}
+	}
+
+	tw.tasksLock.Lock()
+	defer tw.tasksLock.Unlock()
+
+	for _, p := range tasks {
+		t := &task{
+			id:        p.ID,
+			data:      p.Data,
+			handler:   p.Handler,
+			tw:        tw,
+			retry:     p.Retry,
+			attempt:   p.Attempts,
+			priority:  p.Priority,
+			timeout:   p.Timeout,
+			labels:    p.Labels,
+			keyPolicy: p.KeyPolicy,
+		}
+		if t.id == "" {
+			t.id = fmt.Sprintf("task-%d", tw.taskSeq.Add(1))
+		} else if _, ok := tw.tasks[t.id]; ok {
+			GetLogger().Warnf("任务 %s 已存在，跳过恢复", t.id)
+			continue
+		}
+
+		delay := p.Due.Sub(tw.clock.Now())
+		if delay < 0 {
+			delay = 0
+		}
+		tw.tasks[t.id] = t
+		tw.schedule(t, delay)
+		tw.metrics.taskAdded()
+	}
+	return nil
+}
@@ -0,0 +1,585 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTimingWheelMetrics(t *testing.T) {
+	tw := NewTimingWheel(1, 10, WithMetrics(true))
+	if tw.GetMetrics() == nil {
+		t.Fatal("WithMetrics(true) 未生效")
+	}
+	if err := tw.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer tw.Stop()
+
+	done := make(chan struct{})
+	_ = tw.AddTask(nil, func(data any, tc TaskContext) {
+		close(done)
+	}, 0)
+	_ = tw.AddTask(nil, func(data any, tc TaskContext) {
+		panic("boom")
+	}, 0)
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("任务未触发")
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	s := tw.MetricsSnapshot()
+	if s.Added != 2 || s.Fired != 2 || s.Completed != 1 || s.Panicked != 1 {
+		t.Errorf("指标不正确: %+v", s)
+	}
+	if s.SchedulingLag.Count != 2 || s.HandlerLatency.Count != 2 {
+		t.Errorf("直方图计数不正确: %+v", s)
+	}
+	// 兼容旧字段
+	if m := tw.GetMetrics(); m.TotalTasks != 2 || m.CompletedTasks != 1 || m.FailedTasks != 1 || m.ProcessingTasks != 0 {
+		t.Errorf("旧指标字段不正确: %d %d %d %d", m.TotalTasks, m.CompletedTasks, m.FailedTasks, m.ProcessingTasks)
+	}
+	if len(s.SlotTasks) != 10 {
+		t.Errorf("槽位数不正确: %d", len(s.SlotTasks))
+	}
+
+	var buf bytes.Buffer
+	if err := tw.WritePrometheus(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"timing_wheel_tasks_added_total 2",
+		"timing_wheel_tasks_panicked_total 1",
+		`timing_wheel_scheduling_lag_seconds_bucket{le="+Inf"} 2`,
+		"timing_wheel_handler_duration_seconds_count 2",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("输出缺少 %q:\n%s", want, buf.String())
+		}
+	}
+
+	// 已取消的任务不计入槽位任务数，与 SlotCounts 一致
+	_ = tw.AddKeyedTask("cancelled", nil, func(data any, tc TaskContext) {}, 5*time.Second, KeyReplace)
+	_ = tw.AddKeyedTask("kept", nil, func(data any, tc TaskContext) {}, 5*time.Second, KeyReplace)
+	if err := tw.CancelTask("cancelled"); err != nil {
+		t.Fatal(err)
+	}
+	if s = tw.MetricsSnapshot(); s.Pending() != 1 || fmt.Sprint(s.SlotTasks) != fmt.Sprint(tw.SlotCounts()) {
+		t.Errorf("槽位任务数 %v 与 SlotCounts %v 不一致", s.SlotTasks, tw.SlotCounts())
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram([]time.Duration{time.Millisecond, time.Second})
+	h.Observe(time.Microsecond)
+	h.Observe(500 * time.Millisecond)
+	h.Observe(time.Minute)
+
+	s := h.Snapshot()
+	if s.Count != 3 || s.Buckets[0].Count != 1 || s.Buckets[1].Count != 2 {
+		t.Errorf("直方图快照不正确: %+v", s)
+	}
+	if s.Sum != time.Microsecond+500*time.Millisecond+time.Minute {
+		t.Errorf("直方图求和不正确: %v", s.Sum)
+	}
+}
+
+func TestTimingWheelRetry(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	tw := NewTimingWheel(1, 10, WithClock(clock), WithDeadLetterQueue(1))
+	if err := tw.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer tw.Stop()
+
+	attempts := 0
+	err := tw.AddErrTask("order-1", func(data any, tc TaskContext) error {
+		attempts++
+		return errors.New("下游不可用")
+	}, 0, WithTaskRetry(TaskRetryPolicy{MaxAttempts: 3, Backoff: BackoffExponential, Delay: time.Second}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(time.Hour)
+	select {
+	case dl := <-tw.DeadLetters():
+		if dl.Data != "order-1" || dl.Attempts != 3 || dl.Err == nil {
+			t.Errorf("死信不正确: %+v", dl)
+		}
+	default:
+		t.Fatal("未收到死信")
+	}
+	if attempts != 3 {
+		t.Errorf("执行次数不正确: %d", attempts)
+	}
+}
+
+func TestTimingWheelFakeClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	clock := NewFakeClock(start)
+	tw := NewTimingWheel(1, 60, WithClock(clock))
+	if err := tw.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer tw.Stop()
+
+	var fired []time.Time
+	for _, d := range []time.Duration{2 * time.Hour, 30 * time.Minute, 5 * time.Second} {
+		if err := tw.AddTask(d, func(data any, tc TaskContext) {
+			fired = append(fired, clock.Now())
+		}, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clock.Advance(time.Hour)
+	if len(fired) != 2 {
+		t.Fatalf("一小时内应触发2个任务，实际 %d", len(fired))
+	}
+	clock.Advance(time.Hour)
+	if len(fired) != 3 {
+		t.Fatalf("两小时内应触发3个任务，实际 %d", len(fired))
+	}
+	for i, want := range []time.Duration{5 * time.Second, 30 * time.Minute, 2 * time.Hour} {
+		if got := fired[i].Sub(start); got != want {
+			t.Errorf("第 %d 个任务触发时间为 %v，期望 %v", i, got, want)
+		}
+	}
+}
+
+func TestTaskRetryPolicyBackoff(t *testing.T) {
+	p := TaskRetryPolicy{Backoff: BackoffExponential, Delay: time.Second, MaxDelay: 5 * time.Second}
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second} {
+		if got := p.backoff(attempt); got != want {
+			t.Errorf("第 %d 次重试间隔为 %v，期望 %v", attempt, got, want)
+		}
+	}
+}
+
+func TestTimingWheelShutdown(t *testing.T) {
+	tw := NewTimingWheelWithPool(1, 10)
+	if err := tw.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	var finished atomic.Bool
+	_ = tw.AddTask(nil, func(data any, tc TaskContext) {
+		time.Sleep(500 * time.Millisecond)
+		finished.Store(true)
+	}, 0)
+	_ = tw.AddErrTask("later", func(data any, tc TaskContext) error { return nil }, time.Hour,
+		WithTaskKey("later", KeyReplace), WithLabels(map[string]string{"k": "v"}), WithTaskTimeout(2*time.Second),
+		WithTaskRetry(TaskRetryPolicy{MaxAttempts: 3, Delay: time.Second}))
+
+	// 等待第一个任务开始执行
+	time.Sleep(1200 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	pending, err := tw.Shutdown(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !finished.Load() {
+		t.Error("Shutdown 未等待运行中的任务结束")
+	}
+	if len(pending) != 1 || pending[0].Data != "later" {
+		t.Fatalf("未触发任务不正确: %+v", pending)
+	}
+	if err = tw.AddTask(nil, func(data any, tc TaskContext) {}, 0); err == nil {
+		t.Error("停止后仍可添加任务")
+	}
+
+	// 重启后恢复任务
+	if err = tw.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer tw.Stop()
+	if err = tw.Restore(pending); err != nil {
+		t.Fatal(err)
+	}
+	restored := tw.drain()
+	if len(restored) != 1 {
+		t.Fatalf("恢复后的任务数不正确: %d", len(restored))
+	}
+	if p := restored[0]; p.ID != "later" || p.Labels["k"] != "v" || p.Timeout != 2*time.Second ||
+		p.KeyPolicy == nil || *p.KeyPolicy != KeyReplace || p.Retry == nil || p.Retry.MaxAttempts != 3 {
+		t.Errorf("恢复后任务的标签、超时、去重或重试策略丢失: %+v", p)
+	}
+}
+
+func TestTimingWheelShutdownTimeout(t *testing.T) {
+	tw := NewTimingWheel(1, 10, WithDeadLetterQueue(1))
+	if err := tw.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	started, release := make(chan struct{}), make(chan struct{})
+	_ = tw.AddErrTask(nil, func(data any, tc TaskContext) error {
+		close(started)
+		<-release
+		return errors.New("执行失败")
+	}, 0, WithTaskRetry(TaskRetryPolicy{MaxAttempts: 3, Delay: time.Second}))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	pending, err := tw.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("等待超时应返回 ctx 的错误: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("未触发任务不正确: %+v", pending)
+	}
+
+	// 返回未触发任务后结束的任务不再重试，直接投递死信
+	close(release)
+	select {
+	case dl := <-tw.DeadLetters():
+		if dl.Attempts != 1 {
+			t.Errorf("死信执行次数为 %d，期望 1", dl.Attempts)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("超时后结束的失败任务未投递死信")
+	}
+	if tasks := tw.Tasks(); len(tasks) != 0 {
+		t.Errorf("超时后结束的任务不应放回时间轮: %+v", tasks)
+	}
+}
+
+func TestTimingWheelKeyedTask(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	tw := NewTimingWheel(1, 60, WithClock(clock))
+	if err := tw.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer tw.Stop()
+
+	var fired []string
+	handler := func(data any, tc TaskContext) {
+		fired = append(fired, data.(string))
+	}
+
+	// 重复添加时替换，防抖重新计时
+	_ = tw.AddKeyedTask("cart-1", "replace-1", handler, 30*time.Second, KeyReplace)
+	clock.Advance(20 * time.Second)
+	_ = tw.AddKeyedTask("cart-1", "replace-2", handler, 30*time.Second, KeyReplace)
+	// 忽略与保留较早
+	_ = tw.AddKeyedTask("cart-2", "ignore-1", handler, 10*time.Second, KeyIgnore)
+	_ = tw.AddKeyedTask("cart-2", "ignore-2", handler, 5*time.Second, KeyIgnore)
+	_ = tw.AddKeyedTask("cart-3", "earliest-1", handler, 20*time.Second, KeyKeepEarliest)
+	_ = tw.AddKeyedTask("cart-3", "earliest-2", handler, 40*time.Second, KeyKeepEarliest)
+	// 取消
+	_ = tw.AddKeyedTask("cart-4", "cancelled", handler, 10*time.Second, KeyReplace)
+	if err := tw.CancelTask("cart-4"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.CancelTask("cart-4"); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("重复取消应返回 ErrTaskNotFound: %v", err)
+	}
+
+	clock.Advance(time.Minute)
+	want := []string{"ignore-1", "earliest-1", "replace-2"}
+	if strings.Join(fired, ",") != strings.Join(want, ",") {
+		t.Errorf("触发任务为 %v，期望 %v", fired, want)
+	}
+}
+
+func TestTimingWheelUpdateTaskConcurrent(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	tw := NewTimingWheel(1, 10, WithClock(clock))
+	if err := tw.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer tw.Stop()
+
+	var fired atomic.Int32
+	handler := func(data any, tc TaskContext) {
+		fired.Add(1)
+	}
+	// 11秒后触发的任务位于下一槽位且需再转一圈，Advance 时调度循环递减其轮次，
+	// 同时在另一协程更新该任务，由 -race 检查数据竞争
+	_ = tw.AddKeyedTask("job", nil, handler, 11*time.Second, KeyReplace)
+	for i := 0; i < 5; i++ {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			time.Sleep(10 * time.Millisecond)
+			if err := tw.UpdateTask("job", 11*time.Second); err != nil {
+				t.Errorf("更新任务失败: %v", err)
+			}
+		}()
+		clock.Advance(time.Second)
+		wg.Wait()
+	}
+
+	clock.Advance(time.Minute)
+	if n := fired.Load(); n != 1 {
+		t.Errorf("更新后的任务应只触发一次，实际 %d 次", n)
+	}
+}
+
+func TestTimingWheelPriority(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	tw := NewTimingWheel(1, 60, WithClock(clock), WithMaxTasksPerSlot(2))
+	if err := tw.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer tw.Stop()
+
+	var mutex sync.Mutex
+	firedAt := make(map[string]time.Time)
+	for name, priority := range map[string]int{"low": 0, "high": 10, "mid": 5} {
+		err := tw.AddErrTask(name, func(data any, tc TaskContext) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			firedAt[data.(string)] = clock.Now()
+			return nil
+		}, 5*time.Second, WithPriority(priority))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clock.Advance(10 * time.Second)
+	if !firedAt["high"].Equal(firedAt["mid"]) || !firedAt["low"].After(firedAt["high"]) {
+		t.Errorf("低优先级任务应顺延: %v", firedAt)
+	}
+}
+
+func TestTimingWheelDispatchOrder(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	tw := NewTimingWheel(1, 60, WithClock(clock), WithWorkers(1))
+	if err := tw.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer tw.Stop()
+
+	var order []int
+	for i := 0; i < 5; i++ {
+		_ = tw.AddTask(i, func(data any, tc TaskContext) {
+			order = append(order, data.(int))
+		}, 3*time.Second)
+	}
+	clock.Advance(5 * time.Second)
+	for i, v := range order {
+		if i != v {
+			t.Fatalf("同一槽位任务未按顺序执行: %v", order)
+		}
+	}
+	if len(order) != 5 {
+		t.Fatalf("执行任务数不正确: %v", order)
+	}
+}
+
+func TestTimingWheelOverflowReject(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	release := make(chan struct{})
+	var rejected atomic.Int32
+	tw := NewTimingWheel(1, 60, WithClock(clock), WithWorkers(1), WithQueueSize(1),
+		WithOverflowStrategy(OverflowReject), WithMetrics(true),
+		WithErrorHandler(func(data any, err error) {
+			if errors.Is(err, ErrQueueFull) && rejected.Add(1) == 1 {
+				close(release)
+			}
+		}))
+	if err := tw.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer tw.Stop()
+
+	var executed atomic.Int32
+	for i := 0; i < 3; i++ {
+		_ = tw.AddTask(i, func(data any, tc TaskContext) {
+			<-release
+			executed.Add(1)
+		}, 0)
+	}
+	clock.Advance(time.Second)
+	if rejected.Load() == 0 || executed.Load()+rejected.Load() != 3 {
+		t.Errorf("执行 %d 个，拒绝 %d 个", executed.Load(), rejected.Load())
+	}
+	// 分发失败单独计数，不计入执行失败
+	if s := tw.MetricsSnapshot(); s.DispatchFailed != int64(rejected.Load()) || s.Failed != 0 {
+		t.Errorf("分发失败 %d 个，执行失败 %d 个，期望分发失败 %d 个", s.DispatchFailed, s.Failed, rejected.Load())
+	}
+}
+
+func TestTimingWheelTaskContext(t *testing.T) {
+	start := time.Now()
+	clock := NewFakeClock(start)
+	tw := NewTimingWheel(1, 60, WithClock(clock))
+	if err := tw.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer tw.Stop()
+
+	// 时间轮本身仍可作为 TaskContext 传递
+	var _ TaskContext = tw
+
+	started := make(chan struct{})
+	var ctxErr error
+	var info string
+	err := tw.AddErrTask(nil, func(data any, tc TaskContext) error {
+		rc := tc.(TaskRunContext)
+		info = fmt.Sprintf("%s|%d|%s|%v", rc.TaskID(), rc.Attempt(), rc.Labels()["order"], rc.FiredAt().Sub(rc.ScheduledAt()))
+		// 标签为副本，修改不影响任务本身
+		rc.Labels()["order"] = "changed"
+		if rc.Labels()["order"] != "1001" {
+			t.Error("修改 Labels 返回值影响了任务标签")
+		}
+		close(started)
+		<-rc.Context().Done()
+		ctxErr = rc.Context().Err()
+		return ctxErr
+	}, 3*time.Second, WithTaskID("expire-1"), WithLabels(map[string]string{"order": "1001"}),
+		WithTaskRetry(TaskRetryPolicy{MaxAttempts: 3}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		<-started
+		if err := tw.CancelTask("expire-1"); err != nil {
+			t.Error(err)
+		}
+	}()
+	clock.Advance(5 * time.Second)
+
+	if info != "expire-1|1|1001|0s" {
+		t.Errorf("任务上下文信息不正确: %s", info)
+	}
+	if !errors.Is(ctxErr, context.Canceled) {
+		t.Errorf("取消任务后上下文未结束: %v", ctxErr)
+	}
+	// 被取消的任务不再重试
+	if s := tw.drain(); len(s) != 0 {
+		t.Errorf("被取消的任务不应重试: %+v", s)
+	}
+}
+
+func TestTimingWheelIntrospection(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local))
+	tw := NewTimingWheel(1, 10, WithClock(clock), WithFailureHistory(2))
+	if err := tw.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer tw.Stop()
+
+	fired := make(chan string, 4)
+	handler := func(data any, tc TaskContext) error {
+		fired <- tc.(TaskRunContext).TaskID()
+		return fmt.Errorf("失败: %v", data)
+	}
+	for i, d := range []time.Duration{30 * time.Second, 5 * time.Second, 15 * time.Second} {
+		err := tw.AddErrTask(i, handler, d, WithTaskID(fmt.Sprintf("t%d", i)), WithLabels(map[string]string{"n": fmt.Sprint(i)}))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tasks := tw.Tasks()
+	if len(tasks) != 3 || tasks[0].ID != "t1" || tasks[2].ID != "t0" {
+		t.Fatalf("任务列表不正确: %+v", tasks)
+	}
+	if tasks[2].Round != 2 || tasks[0].Labels["n"] != "1" {
+		t.Errorf("任务信息不正确: %+v", tasks[2])
+	}
+	if next := tw.PeekDue(2); len(next) != 2 || next[1].ID != "t2" {
+		t.Errorf("PeekDue 结果不正确: %+v", next)
+	}
+	total := 0
+	for _, n := range tw.SlotCounts() {
+		total += n
+	}
+	if total != 3 {
+		t.Errorf("槽位任务总数为 %d，期望 3", total)
+	}
+
+	if err := tw.FireNow("t0"); err != nil {
+		t.Fatal(err)
+	}
+	if id := <-fired; id != "t0" {
+		t.Errorf("立即触发的任务为 %s，期望 t0", id)
+	}
+	if err := tw.FireNow("t0"); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("已触发的任务不应再次触发: %v", err)
+	}
+
+	clock.Advance(20 * time.Second)
+	failures := tw.RecentFailures()
+	if len(failures) != 2 || failures[0].ID != "t2" || failures[1].ID != "t1" {
+		t.Fatalf("失败记录不正确: %+v", failures)
+	}
+	if failures[0].Attempt != 1 || failures[0].Error != "失败: 2" {
+		t.Errorf("失败记录内容不正确: %+v", failures[0])
+	}
+	if len(tw.Tasks()) != 0 {
+		t.Errorf("任务应已全部执行: %+v", tw.Tasks())
+	}
+}
+
+func TestTimingWheelFireNowConcurrent(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	tw := NewTimingWheel(1, 10, WithClock(clock))
+	if err := tw.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer tw.Stop()
+
+	var fired atomic.Int32
+	handler := func(data any, tc TaskContext) {
+		fired.Add(1)
+	}
+	// 处理函数中立即触发其他任务，手动时钟的 Advance 应等待该任务执行完毕
+	_ = tw.AddKeyedTask("inner", nil, handler, time.Hour, KeyReplace)
+	_ = tw.AddKeyedTask("outer", nil, func(data any, tc TaskContext) {
+		if err := tw.FireNow("inner"); err != nil {
+			t.Errorf("处理函数中立即触发任务失败: %v", err)
+		}
+	}, time.Second, KeyReplace)
+	clock.Advance(time.Second)
+	if n := fired.Load(); n != 1 {
+		t.Fatalf("Advance 返回时立即触发的任务应已执行，实际执行 %d 次", n)
+	}
+
+	// 调度循环等待任务结束的同时在另一协程立即触发任务，由 -race 检查计数的使用
+	const n = 50
+	for i := 0; i < n; i++ {
+		_ = tw.AddKeyedTask(fmt.Sprintf("job-%d", i), nil, handler, 11*time.Second, KeyReplace)
+		_ = tw.AddKeyedTask(fmt.Sprintf("tick-%d", i), nil, handler, time.Duration(i%5+2)*time.Second, KeyReplace)
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			if err := tw.FireNow(fmt.Sprintf("job-%d", i)); err != nil {
+				t.Errorf("立即触发任务失败: %v", err)
+			}
+		}
+	}()
+	for i := 0; i < 6; i++ {
+		clock.Advance(time.Second)
+	}
+	wg.Wait()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for fired.Load() != 1+2*n && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := fired.Load(); got != 1+2*n {
+		t.Errorf("执行的任务数为 %d，期望 %d", got, 1+2*n)
+	}
+}